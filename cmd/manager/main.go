@@ -10,10 +10,15 @@ import (
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/jmainguy/coastie-operator/pkg/agent"
 	"github.com/jmainguy/coastie-operator/pkg/apis"
+	operatorconfig "github.com/jmainguy/coastie-operator/pkg/config"
 	"github.com/jmainguy/coastie-operator/pkg/controller"
+	"github.com/jmainguy/coastie-operator/pkg/health"
+	"github.com/jmainguy/coastie-operator/pkg/heartbeat"
+	"github.com/jmainguy/coastie-operator/pkg/statusapi"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
 
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	"github.com/operator-framework/operator-sdk/pkg/metrics"
@@ -26,11 +31,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
 )
 
-// Change below variables to serve metrics on different host or port.
-var (
-	metricsHost       = "0.0.0.0"
-	metricsPort int32 = 8383
-)
 var log = logf.Log.WithName("cmd")
 
 func printVersion() {
@@ -40,6 +40,16 @@ func printVersion() {
 }
 
 func main() {
+	// The pods of the agent based tests run the operator image as an agent
+	if len(os.Args) > 1 && os.Args[1] == agent.Command {
+		logf.SetLogger(zap.Logger())
+		if err := agent.Main(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Add the zap logger flag set to the CLI. The flag set must
 	// be added before calling pflag.Parse().
 	pflag.CommandLine.AddFlagSet(zap.FlagSet())
@@ -48,8 +58,25 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	// Add the operator configuration flags
+	operatorconfig.AddFlags(pflag.CommandLine)
+
 	pflag.Parse()
 
+	// Load the operator configuration, from a file, the environment and flags
+	operatorConfig, err := operatorconfig.Load(pflag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// The log level of the configuration applies unless --zap-level is set
+	if operatorConfig.LogLevel != "" && !pflag.CommandLine.Changed("zap-level") {
+		if err := pflag.CommandLine.Set("zap-level", operatorConfig.LogLevel); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
 	// Use a zap logr.Logger implementation. If none of the zap
 	// flags are configured (or if the zap flag set is not being
 	// used), this defaults to a production zap logger.
@@ -62,11 +89,7 @@ func main() {
 
 	printVersion()
 
-	namespace, err := k8sutil.GetWatchNamespace()
-	if err != nil {
-		log.Error(err, "Failed to get watch namespace")
-		os.Exit(1)
-	}
+	log.Info("Loaded configuration", "WatchNamespaces", operatorConfig.WatchNamespaces, "Interval", operatorConfig.Interval.Duration, "MaxConcurrentReconciles", operatorConfig.MaxConcurrentReconciles)
 
 	// Get a config to talk to the apiserver
 	cfg, err := config.GetConfig()
@@ -77,6 +100,33 @@ func main() {
 
 	ctx := context.TODO()
 
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		log.Error(err, "Failed to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error(err, "Failed to flush traces")
+		}
+	}()
+
+	stop := signals.SetupSignalHandler()
+
+	// Serve the liveness and readiness endpoints while waiting to become the leader, so a rolling update does
+	// not kill the new pod before the previous leader is gone
+	var healthServer *health.Server
+	if operatorConfig.HealthProbeAddress != "" {
+		healthServer = health.New(operatorConfig.HealthProbeAddress, operatorConfig.WatchdogWindow.Duration)
+		go func() {
+			if err := healthServer.Start(stop); err != nil {
+				log.Error(err, "Failed to serve the health endpoints")
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Become the leader before proceeding
 	err = leader.Become(ctx, "coastie-operator-lock")
 	if err != nil {
@@ -86,9 +136,9 @@ func main() {
 
 	// Create a new Cmd to provide shared dependencies and start components
 	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          namespace,
+		Namespace:          operatorConfig.Namespace(),
 		MapperProvider:     restmapper.NewDynamicRESTMapper,
-		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		MetricsBindAddress: operatorConfig.MetricsAddress,
 	})
 	if err != nil {
 		log.Error(err, "")
@@ -104,13 +154,35 @@ func main() {
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddToManager(mgr, operatorConfig); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	// Report the readiness of the leader once its caches are synced
+	if healthServer != nil {
+		if err := healthServer.Lead(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
+	// Serve the read-only status API
+	if operatorConfig.StatusAPIAddress != "" {
+		if err := statusapi.Add(mgr, operatorConfig.StatusAPIAddress); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
+	// Send heartbeats, when configured
+	if err := heartbeat.Add(mgr, operatorConfig); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
 	// Create Service object to expose the metrics port.
-	_, err = metrics.ExposeMetricsPort(ctx, metricsPort)
+	_, err = metrics.ExposeMetricsPort(ctx, operatorConfig.MetricsPort())
 	if err != nil {
 		log.Info(err.Error())
 	}
@@ -118,8 +190,9 @@ func main() {
 	log.Info("Starting the Cmd.")
 
 	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stop); err != nil {
 		log.Error(err, "Manager exited non-zero")
+		shutdownTracing(context.Background())
 		os.Exit(1)
 	}
 }
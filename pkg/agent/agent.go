@@ -0,0 +1,322 @@
+// Package agent is the process run by the pods of the agent based tests. The operator image runs it when started
+// with the agent command, and the operator drives it through its control API.
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("agent")
+
+// Command is the first argument of the operator binary starting the agent
+const Command = "agent"
+
+// Ports the agent listens on. The control API is only called by the operator on the pod IP of the agent, the
+// public port serves the Services and Ingresses in front of the agents
+const (
+	ControlPort = 9090
+	SinkPort    = 9091
+	EchoPort    = 9092
+	GRPCPort    = 9093
+	PublicPort  = 9094
+)
+
+// Environment variables the pod and node names of the agent are read from, set through the downward API
+const (
+	PodNameEnv  = "POD_NAME"
+	NodeNameEnv = "NODE_NAME"
+)
+
+// TokenEnv is the environment variable the token of the control API is read from, set from the TokenKey of the
+// Secret the operator creates for every Coastie
+const (
+	TokenEnv = "AGENT_TOKEN"
+	TokenKey = "token"
+)
+
+// Limits of the requests of the control API, so a caller can not have an agent send or wait for long. The mtu
+// search waits for dozens of packets, MaxMTUTimeout bounds the wait for each of them
+const (
+	MaxBandwidthBytes = 1024 * 1024 * 1024
+	MaxTimeout        = 5 * time.Minute
+	MaxMTUTimeout     = 10 * time.Second
+)
+
+// Size of the buffer data is sent and received with
+const chunkSize = 64 * 1024
+
+// WhoAmI is the answer of the agent to /whoami, telling apart the endpoints of a Service
+type WhoAmI struct {
+	Pod  string `json:"pod"`
+	Node string `json:"node"`
+}
+
+// Main parses the agent flags from args and serves until one of the listeners fails
+func Main(args []string) error {
+	fs := pflag.NewFlagSet(Command, pflag.ContinueOnError)
+	controlAddress := fs.String("control-address", fmt.Sprintf("0.0.0.0:%d", ControlPort), "Address the control API is served on")
+	sinkAddress := fs.String("sink-address", fmt.Sprintf("0.0.0.0:%d", SinkPort), "Address the TCP sink of the bandwidth test listens on")
+	echoAddress := fs.String("echo-address", fmt.Sprintf("0.0.0.0:%d", EchoPort), "Address the TCP and UDP echo servers listen on")
+	grpcAddress := fs.String("grpc-address", fmt.Sprintf("0.0.0.0:%d", GRPCPort), "Address the gRPC health server of the grpc test listens on")
+	publicAddress := fs.String("public-address", fmt.Sprintf("0.0.0.0:%d", PublicPort), "Address /whoami and the WebSocket echo are served on")
+	egressTargets := fs.StringArray("egress-target", nil, "TYPE=TARGET the egress test may probe, repeated for every target")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	token := os.Getenv(TokenEnv)
+	if token == "" {
+		return fmt.Errorf("the %s environment variable must hold the token of the control API", TokenEnv)
+	}
+	egress, err := parseEgressTargets(*egressTargets)
+	if err != nil {
+		return err
+	}
+
+	sink, err := net.Listen("tcp", *sinkAddress)
+	if err != nil {
+		return err
+	}
+	tcpEcho, err := net.Listen("tcp", *echoAddress)
+	if err != nil {
+		return err
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", *echoAddress)
+	if err != nil {
+		return err
+	}
+	udpEcho, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	grpcListener, err := net.Listen("tcp", *grpcAddress)
+	if err != nil {
+		return err
+	}
+	// Echoed datagrams must not be fragmented on their way back either
+	if err := setDontFragment(udpEcho); err != nil {
+		log.Error(err, "Failed to set the don't fragment bit on the UDP echo server")
+	}
+	errs := make(chan error, 6)
+	go func() {
+		errs <- serveSink(sink)
+	}()
+	go func() {
+		errs <- serveTCPEcho(tcpEcho)
+	}()
+	go func() {
+		errs <- serveUDPEcho(udpEcho)
+	}()
+	go func() {
+		errs <- serveGRPCHealth(grpcListener)
+	}()
+
+	healthz := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthz)
+	mux.Handle("/bandwidth", authorize(token, handleBandwidth))
+	mux.Handle("/mtu", authorize(token, handleMTU))
+	mux.Handle("/connect", authorize(token, handleConnect))
+	mux.Handle("/egress", authorize(token, egress.handle))
+	go func() {
+		errs <- http.ListenAndServe(*controlAddress, mux)
+	}()
+
+	public := http.NewServeMux()
+	public.HandleFunc("/healthz", healthz)
+	public.HandleFunc(WebSocketPath, handleWebSocket)
+	public.HandleFunc("/whoami", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(WhoAmI{Pod: os.Getenv(PodNameEnv), Node: os.Getenv(NodeNameEnv)})
+	})
+	go func() {
+		errs <- http.ListenAndServe(*publicAddress, public)
+	}()
+	log.Info("Agent started", "ControlAddress", *controlAddress, "PublicAddress", *publicAddress, "SinkAddress", *sinkAddress, "EchoAddress", *echoAddress, "GRPCAddress", *grpcAddress)
+	return <-errs
+}
+
+// authorize only lets the requests bearing token through to handler
+func authorize(token string, handler http.HandlerFunc) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, req)
+	})
+}
+
+// parseTimeout returns the timeout query parameter of req, which must be greater than 0 and at most max
+func parseTimeout(req *http.Request, max time.Duration) (timeout time.Duration, err error) {
+	timeout, err = time.ParseDuration(req.URL.Query().Get("timeout"))
+	if err != nil || timeout <= 0 || timeout > max {
+		return 0, fmt.Errorf("timeout must be a duration greater than 0 and at most %s", max)
+	}
+	return timeout, nil
+}
+
+// agentTarget checks that target is the given port of an IP, the agents only send to the other agents
+func agentTarget(target string, port int) error {
+	host, p, err := net.SplitHostPort(target)
+	if err != nil || net.ParseIP(host) == nil || p != strconv.Itoa(port) {
+		return fmt.Errorf("target must be an IP with port %d", port)
+	}
+	return nil
+}
+
+// serveGRPCHealth serves the gRPC health service, reporting the agent as serving
+func serveGRPCHealth(l net.Listener) error {
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return server.Serve(l)
+}
+
+// serveSink reads everything sent on every connection, and answers with the number of bytes read once the
+// sender is done writing
+func serveSink(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			n, err := io.CopyBuffer(ioutil.Discard, c, make([]byte, chunkSize))
+			if err != nil {
+				log.Error(err, "Failed to read from sender", "Sender", c.RemoteAddr().String())
+				return
+			}
+			var received [8]byte
+			binary.BigEndian.PutUint64(received[:], uint64(n))
+			c.Write(received[:])
+		}(c)
+	}
+}
+
+// handleBandwidth sends bytes to the sink at target and writes the BandwidthResult
+func handleBandwidth(w http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	if err := agentTarget(target, SinkPort); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseInt(req.URL.Query().Get("bytes"), 10, 64)
+	if err != nil || size <= 0 || size > MaxBandwidthBytes {
+		http.Error(w, fmt.Sprintf("bytes must be a number greater than 0 and at most %d", MaxBandwidthBytes), http.StatusBadRequest)
+		return
+	}
+	timeout, err := parseTimeout(req, MaxTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := sendBandwidth(target, size, timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// sendBandwidth sends size bytes to the sink at target, the transfer ends once the sink acknowledged them
+func sendBandwidth(target string, size int64, timeout time.Duration) (result BandwidthResult, err error) {
+	start := time.Now()
+	c, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return result, err
+	}
+	defer c.Close()
+	c.SetDeadline(start.Add(timeout))
+
+	chunk := make([]byte, chunkSize)
+	remaining := size
+	for remaining > 0 {
+		n := int64(len(chunk))
+		if remaining < n {
+			n = remaining
+		}
+		written, err := c.Write(chunk[:n])
+		remaining -= int64(written)
+		if err != nil {
+			return result, fmt.Errorf("sent %d of %d bytes: %s", size-remaining, size, err)
+		}
+	}
+	if tcp, ok := c.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+	var received [8]byte
+	_, err = io.ReadFull(c, received[:])
+	if err != nil {
+		return result, fmt.Errorf("waiting for the sink to acknowledge: %s", err)
+	}
+	result.Duration = time.Since(start)
+	result.Bytes = int64(binary.BigEndian.Uint64(received[:]))
+	if result.Bytes != size {
+		return result, fmt.Errorf("sink received %d of %d bytes", result.Bytes, size)
+	}
+	return result, nil
+}
+
+// ConnectResult tells whether the agent could exchange data with the echo of another agent
+type ConnectResult struct {
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleConnect sends a few bytes to the TCP echo at target and writes the ConnectResult. Failing to connect is
+// a result, not an error, as the networkpolicy test expects some connections to be blocked
+func handleConnect(w http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	if err := agentTarget(target, EchoPort); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout, err := parseTimeout(req, MaxTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := ConnectResult{Connected: true}
+	if err := connect(target, timeout); err != nil {
+		result = ConnectResult{Error: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// connect returns nil once a payload sent to the TCP echo at target came back
+func connect(target string, timeout time.Duration) error {
+	c, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(timeout))
+	payload := []byte("coastie\n")
+	if _, err := c.Write(payload); err != nil {
+		return err
+	}
+	response := make([]byte, len(payload))
+	_, err = io.ReadFull(c, response)
+	return err
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAgentTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		port   int
+		valid  bool
+	}{
+		{name: "sink of an agent", target: "10.0.0.1:9091", port: SinkPort, valid: true},
+		{name: "echo of an IPv6 agent", target: "[fd00::1]:9092", port: EchoPort, valid: true},
+		{name: "other port", target: "10.0.0.1:22", port: SinkPort},
+		{name: "host name", target: "kubernetes.default:9091", port: SinkPort},
+		{name: "no port", target: "10.0.0.1", port: EchoPort},
+		{name: "empty", port: EchoPort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := agentTarget(tt.target, tt.port)
+			if (err == nil) != tt.valid {
+				t.Errorf("agentTarget(%q, %d): expected valid %t, got %v", tt.target, tt.port, tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	handler := authorize("secret", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name          string
+		authorization string
+		code          int
+	}{
+		{name: "token", authorization: "Bearer secret", code: http.StatusOK},
+		{name: "no token", code: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", code: http.StatusUnauthorized},
+		{name: "token without scheme", authorization: "secret", code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/bandwidth", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("expected status code %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestEgressTargets(t *testing.T) {
+	targets, err := parseEgressTargets([]string{"tcp=registry-1.docker.io:443", "http=https://example.com/?a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{name: "other target", query: "type=tcp&target=127.0.0.1:1&timeout=1ms", code: http.StatusForbidden},
+		{name: "listed target with a timeout over the limit", query: "type=http&target=https%3A%2F%2Fexample.com%2F%3Fa%3Db&timeout=10m", code: http.StatusBadRequest},
+		{name: "type of another target", query: "type=dns&target=registry-1.docker.io:443&timeout=1s", code: http.StatusForbidden},
+		{name: "unknown type", query: "type=udp&target=registry-1.docker.io:443&timeout=1s", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			targets.handle(w, httptest.NewRequest(http.MethodGet, "/egress?"+tt.query, nil))
+			if w.Code != tt.code {
+				t.Errorf("expected status code %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	for _, v := range []string{"tcp", "udp=example.com:53", "dns="} {
+		if _, err := parseEgressTargets([]string{v}); err == nil {
+			t.Errorf("expected egress target %q to be rejected", v)
+		}
+	}
+}
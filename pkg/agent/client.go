@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthResult is the outcome of a transfer to the sink of another agent
+type BandwidthResult struct {
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Mbps returns the throughput of the transfer in megabits per second
+func (r BandwidthResult) Mbps() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) * 8 / r.Duration.Seconds() / 1e6
+}
+
+// Client calls the control API of the agents of a Coastie, with the token of their Secret
+type Client struct {
+	token string
+}
+
+// NewClient returns a Client calling the agents with token
+func NewClient(token string) *Client {
+	return &Client{token: token}
+}
+
+// Bandwidth asks the agent at agentIP to send size bytes to the sink of the agent at targetIP
+func (c *Client) Bandwidth(ctx context.Context, agentIP, targetIP string, size int64, timeout time.Duration) (result BandwidthResult, err error) {
+	query := url.Values{}
+	query.Set("target", net.JoinHostPort(targetIP, strconv.Itoa(SinkPort)))
+	query.Set("bytes", strconv.FormatInt(size, 10))
+	query.Set("timeout", timeout.String())
+	err = c.call(ctx, agentIP, "/bandwidth", query, timeout, &result)
+	return result, err
+}
+
+// MTU asks the agent at agentIP for the largest packets which make it to the echo of the agent at targetIP and
+// back. The path MTU is searched up to expected, or up to the MTU of the pod interface when 0
+func (c *Client) MTU(ctx context.Context, agentIP, targetIP string, expected int, timeout time.Duration) (result MTUResult, err error) {
+	query := url.Values{}
+	query.Set("target", net.JoinHostPort(targetIP, strconv.Itoa(EchoPort)))
+	if expected > 0 {
+		query.Set("expected", strconv.Itoa(expected))
+	}
+	query.Set("timeout", timeout.String())
+	// Every size tried waits up to timeout, when every attempt at it is dropped
+	err = c.call(ctx, agentIP, "/mtu", query, mtuDuration(expected, timeout), &result)
+	return result, err
+}
+
+// Connect asks the agent at agentIP whether it can exchange data with the TCP echo of the agent at targetIP
+func (c *Client) Connect(ctx context.Context, agentIP, targetIP string, timeout time.Duration) (result ConnectResult, err error) {
+	query := url.Values{}
+	query.Set("target", net.JoinHostPort(targetIP, strconv.Itoa(EchoPort)))
+	query.Set("timeout", timeout.String())
+	err = c.call(ctx, agentIP, "/connect", query, timeout, &result)
+	return result, err
+}
+
+// Egress asks the agent at agentIP to probe target, a host:port, URL or host name depending on probeType
+func (c *Client) Egress(ctx context.Context, agentIP, probeType, target string, timeout time.Duration) (result EgressResult, err error) {
+	query := url.Values{}
+	query.Set("type", probeType)
+	query.Set("target", target)
+	query.Set("timeout", timeout.String())
+	err = c.call(ctx, agentIP, "/egress", query, timeout, &result)
+	return result, err
+}
+
+// call sends a request to the control API of the agent at agentIP and decodes its JSON response into out.
+// The agent is given timeout to carry out the request
+func (c *Client) call(ctx context.Context, agentIP, path string, query url.Values, timeout time.Duration, out interface{}) error {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     net.JoinHostPort(agentIP, strconv.Itoa(ControlPort)),
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	// Leave the agent some time to report its own timeout
+	client := &http.Client{Timeout: timeout + 5*time.Second}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("agent %s: %s", agentIP, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package agent
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets the don't fragment bit on the packets sent on c, so packets larger than the path MTU
+// are dropped rather than fragmented
+func setDontFragment(c *net.UDPConn) error {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	level, option, value := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO
+	if addr, ok := c.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil && addr.IP.To16() != nil {
+		level, option, value = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, option, value)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package agent
+
+import (
+	"errors"
+	"net"
+)
+
+// setDontFragment is only supported on linux, where the agent runs
+func setDontFragment(c *net.UDPConn) error {
+	return errors.New("the don't fragment bit can only be set on linux")
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Types of the targets of the egress test
+const (
+	EgressTCP  = "tcp"
+	EgressHTTP = "http"
+	EgressDNS  = "dns"
+)
+
+// EgressResult is the outcome of a probe of a target outside of the cluster
+type EgressResult struct {
+	// Reachable is true when the target answered, whatever the status code of an http target
+	Reachable bool `json:"reachable"`
+	// Duration of the probe
+	Duration time.Duration `json:"duration"`
+	// StatusCode answered by an http target
+	StatusCode int `json:"statuscode,omitempty"`
+	// Addresses a dns target resolved to
+	Addresses []string `json:"addresses,omitempty"`
+	// Error of an unreachable target
+	Error string `json:"error,omitempty"`
+}
+
+// egressTargets are the targets the agent may probe, keyed by TYPE=TARGET as given with --egress-target. The
+// operator starts the agents of the egress test with the targets of the Coastie, the other agents probe none
+type egressTargets map[string]bool
+
+// EgressTargetArg returns the --egress-target argument letting the agent probe target of the given type
+func EgressTargetArg(probeType, target string) string {
+	return fmt.Sprintf("--egress-target=%s=%s", probeType, target)
+}
+
+// parseEgressTargets parses the TYPE=TARGET values of --egress-target
+func parseEgressTargets(values []string) (targets egressTargets, err error) {
+	targets = make(egressTargets)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[1] == "" || !egressType(parts[0]) {
+			return nil, fmt.Errorf("egress target %q must be TYPE=TARGET, with a type of %s, %s or %s", v, EgressTCP, EgressHTTP, EgressDNS)
+		}
+		targets[v] = true
+	}
+	return targets, nil
+}
+
+func egressType(probeType string) bool {
+	return probeType == EgressTCP || probeType == EgressHTTP || probeType == EgressDNS
+}
+
+// handle probes the target of the given type and writes the EgressResult. An unreachable target is a result,
+// not an error, so the operator tells it apart from an agent which did not answer
+func (targets egressTargets) handle(w http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	probeType := req.URL.Query().Get("type")
+	if !egressType(probeType) {
+		http.Error(w, fmt.Sprintf("type must be %s, %s or %s", EgressTCP, EgressHTTP, EgressDNS), http.StatusBadRequest)
+		return
+	}
+	if !targets[probeType+"="+target] {
+		http.Error(w, fmt.Sprintf("%s target %s is not one of the egress targets of the agent", probeType, target), http.StatusForbidden)
+		return
+	}
+	timeout, err := parseTimeout(req, MaxTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := probeEgress(probeType, target, timeout)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// probeEgress connects to, sends a GET to or resolves target, depending on probeType
+func probeEgress(probeType, target string, timeout time.Duration) (result EgressResult) {
+	start := time.Now()
+	var err error
+	switch probeType {
+	case EgressTCP:
+		var c net.Conn
+		c, err = net.DialTimeout("tcp", target, timeout)
+		if err == nil {
+			c.Close()
+		}
+	case EgressHTTP:
+		result.StatusCode, err = getEgress(target, timeout)
+	case EgressDNS:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		result.Addresses, err = net.DefaultResolver.LookupHost(ctx, target)
+		cancel()
+	}
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Reachable = true
+	return result
+}
+
+// getEgress sends a GET to url through the proxy of the environment, if any, and returns the status code. A
+// redirect is not followed, its status code is the answer of the target
+func getEgress(url string, timeout time.Duration) (statusCode int, err error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: timeout,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers added to a UDP payload to make up the IP packet
+const (
+	udp4Overhead = 20 + 8
+	udp6Overhead = 40 + 8
+)
+
+// MinMTU is the smallest MTU every IPv4 host has to accept, the search for the path MTU starts from it. MaxMTU is
+// the largest IP packet
+const (
+	MinMTU = 576
+	MaxMTU = 65535
+)
+
+// Times a datagram is sent before its size is given up on, as UDP may drop it
+const udpAttempts = 3
+
+// Sizes of the TCP payloads echoed by the mtu test, each one is sent once the previous one made it back
+var tcpPayloadSizes = []int{1024, 4 * 1024, 16 * 1024, 64 * 1024}
+
+// MTUResult is the largest packets which made it to another agent and back
+type MTUResult struct {
+	// PodMTU is the MTU of the interface of the pod the packets were sent from
+	PodMTU int `json:"podmtu"`
+	// PathMTU is the size of the largest UDP packet echoed with the don't fragment bit set
+	PathMTU int `json:"pathmtu"`
+	// TCPBytes is the largest TCP payload echoed
+	TCPBytes int `json:"tcpbytes"`
+	// MaxTCPBytes is the largest TCP payload tried
+	MaxTCPBytes int `json:"maxtcpbytes"`
+}
+
+// handleMTU searches the largest UDP packet and TCP payload echoed by the agent at target
+func handleMTU(w http.ResponseWriter, req *http.Request) {
+	target := req.URL.Query().Get("target")
+	if err := agentTarget(target, EchoPort); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout, err := parseTimeout(req, MaxMTUTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expected := 0
+	if v := req.URL.Query().Get("expected"); v != "" {
+		expected, err = strconv.Atoi(v)
+		if err != nil || expected < MinMTU || expected > MaxMTU {
+			http.Error(w, fmt.Sprintf("expected must be a number between %d and %d", MinMTU, MaxMTU), http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := probeMTU(target, expected, timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// probeMTU searches the largest UDP packet, up to expected or the MTU of the pod interface when 0, echoed by
+// target with the don't fragment bit set, then sends TCP payloads of increasing size to the echo of target
+func probeMTU(target string, expected int, timeout time.Duration) (result MTUResult, err error) {
+	c, err := net.DialTimeout("udp", target, timeout)
+	if err != nil {
+		return result, err
+	}
+	defer c.Close()
+	udp := c.(*net.UDPConn)
+	if err := setDontFragment(udp); err != nil {
+		return result, fmt.Errorf("setting the don't fragment bit: %s", err)
+	}
+	local := udp.LocalAddr().(*net.UDPAddr)
+	result.PodMTU, err = interfaceMTU(local.IP)
+	if err != nil {
+		return result, err
+	}
+	if expected == 0 {
+		expected = result.PodMTU
+	}
+	overhead := udp4Overhead
+	if local.IP.To4() == nil {
+		overhead = udp6Overhead
+	}
+
+	// Try the expected size first, which works on a healthy network, and only search below it when it does not
+	low, high := MinMTU, expected
+	if !echoUDP(udp, high-overhead, timeout) {
+		if !echoUDP(udp, low-overhead, timeout) {
+			return result, fmt.Errorf("no UDP packet of %d bytes made it to %s and back", low, target)
+		}
+		for high-low > 1 {
+			size := (low + high) / 2
+			if echoUDP(udp, size-overhead, timeout) {
+				low = size
+			} else {
+				high = size
+			}
+		}
+		high = low
+	}
+	result.PathMTU = high
+
+	result.MaxTCPBytes = tcpPayloadSizes[len(tcpPayloadSizes)-1]
+	result.TCPBytes, err = echoTCP(target, timeout)
+	return result, err
+}
+
+// mtuDuration returns how long probeMTU takes at most, when every datagram of the search is dropped and every TCP
+// payload stalls. The search runs up to expected, up to the largest packet when 0 as the MTU of the pod is not known
+// before the search
+func mtuDuration(expected int, timeout time.Duration) time.Duration {
+	if expected == 0 {
+		expected = MaxMTU
+	}
+	// The expected and smallest sizes, then a binary search between them
+	sizes := 2
+	for span := expected - MinMTU; span > 1; span = (span + 1) / 2 {
+		sizes++
+	}
+	// The UDP dial, every size sent udpAttempts times, the TCP dial and every TCP payload
+	steps := 1 + sizes*udpAttempts + 1 + len(tcpPayloadSizes)
+	return time.Duration(steps) * timeout
+}
+
+// echoUDP returns true when a datagram of size bytes is echoed back, it is sent up to udpAttempts times as UDP
+// may drop it
+func echoUDP(c *net.UDPConn, size int, timeout time.Duration) bool {
+	payload := make([]byte, size)
+	rand.Read(payload)
+	response := make([]byte, size+1)
+	for attempt := 0; attempt < udpAttempts; attempt++ {
+		c.SetDeadline(time.Now().Add(timeout))
+		if _, err := c.Write(payload); err != nil {
+			// The kernel refuses datagrams larger than the path MTU it already knows of
+			continue
+		}
+		n, err := c.Read(response)
+		if err == nil && bytes.Equal(response[:n], payload) {
+			return true
+		}
+	}
+	return false
+}
+
+// echoTCP sends TCP payloads of increasing size to the echo at target and returns the largest one echoed back.
+// A path MTU black hole stalls the full size segments of the larger payloads
+func echoTCP(target string, timeout time.Duration) (largest int, err error) {
+	c, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	for _, size := range tcpPayloadSizes {
+		payload := make([]byte, size)
+		rand.Read(payload)
+		c.SetDeadline(time.Now().Add(timeout))
+		errs := make(chan error, 1)
+		go func() {
+			_, err := c.Write(payload)
+			errs <- err
+		}()
+		response := make([]byte, size)
+		_, err := io.ReadFull(c, response)
+		if werr := <-errs; err == nil {
+			err = werr
+		}
+		if err != nil || !bytes.Equal(response, payload) {
+			return largest, nil
+		}
+		largest = size
+	}
+	return largest, nil
+}
+
+// interfaceMTU returns the MTU of the interface holding ip
+func interfaceMTU(ip net.IP) (int, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, i := range interfaces {
+		addrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return i.MTU, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no interface holds %s", ip)
+}
+
+// serveUDPEcho sends every datagram back to its sender
+func serveUDPEcho(c *net.UDPConn) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := c.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		c.WriteToUDP(buf[:n], addr)
+	}
+}
+
+// serveTCPEcho sends everything read on every connection back
+func serveTCPEcho(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			io.CopyBuffer(c, c, make([]byte, chunkSize))
+		}(c)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+// searchSizes returns the number of sizes probeMTU tries up to expected when every size of the binary search is
+// echoed, or when none is
+func searchSizes(expected int, echoed bool) int {
+	sizes := 2
+	low, high := MinMTU, expected
+	for high-low > 1 {
+		sizes++
+		size := (low + high) / 2
+		if echoed {
+			low = size
+		} else {
+			high = size
+		}
+	}
+	return sizes
+}
+
+func TestMTUDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int
+		timeout  time.Duration
+		duration time.Duration
+	}{
+		{name: "smallest MTU", expected: MinMTU, timeout: time.Second, duration: 12 * time.Second},
+		{name: "one above the smallest MTU", expected: MinMTU + 1, timeout: time.Second, duration: 12 * time.Second},
+		{name: "ethernet", expected: 1500, timeout: time.Second},
+		{name: "jumbo frames", expected: 9000, timeout: 2 * time.Second},
+		{name: "largest packet", expected: MaxMTU, timeout: time.Second},
+		{name: "MTU of the pod", expected: 0, timeout: MaxMTUTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mtuDuration(tt.expected, tt.timeout)
+			if tt.duration > 0 && got != tt.duration {
+				t.Errorf("expected %s, got %s", tt.duration, got)
+			}
+			expected := tt.expected
+			if expected == 0 {
+				expected = MaxMTU
+			}
+			// Every size is sent udpAttempts times, after the UDP dial and before the TCP dial and payloads
+			for _, echoed := range []bool{true, false} {
+				steps := 1 + searchSizes(expected, echoed)*udpAttempts + 1 + len(tcpPayloadSizes)
+				if bound := time.Duration(steps) * tt.timeout; got < bound {
+					t.Errorf("%s is shorter than the %s the search takes when echoed is %t", got, bound, echoed)
+				}
+			}
+			if max := mtuDuration(MaxMTU, MaxMTUTimeout); got > max {
+				t.Errorf("%s exceeds the %s of the largest search", got, max)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketPath is the path the agent accepts WebSockets on
+const WebSocketPath = "/ws"
+
+// Every origin may open a WebSocket, the test connects through whatever host the Ingress answers on
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(req *http.Request) bool {
+		return true
+	},
+}
+
+// handleWebSocket upgrades the request to a WebSocket, sends the WhoAmI of the agent, then echoes every message
+// until the connection is closed
+func handleWebSocket(w http.ResponseWriter, req *http.Request) {
+	c, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader already answered the request
+		return
+	}
+	defer c.Close()
+	if err := c.WriteJSON(WhoAmI{Pod: os.Getenv(PodNameEnv), Node: os.Getenv(NodeNameEnv)}); err != nil {
+		return
+	}
+	for {
+		messageType, message, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := c.WriteMessage(messageType, message); err != nil {
+			log.Error(err, "Failed to echo WebSocket message", "Client", c.RemoteAddr().String())
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterCoastieSpec defines the desired state of ClusterCoastie
+// +k8s:openapi-gen=true
+type ClusterCoastieSpec struct {
+	// TestNamespace is the namespace created and owned by the ClusterCoastie to run its tests in.
+	// Defaults to coastie-<name>
+	TestNamespace string `json:"testnamespace,omitempty"`
+	// NodeSelector is set as the node-selector annotation of the test namespace.
+	// Leave empty so the test DaemonSets are allowed on every node
+	NodeSelector string `json:"nodeselector,omitempty"`
+	// Quota overrides the ResourceQuota that is otherwise sized from the number of nodes and tests
+	Quota corev1.ResourceList `json:"quota,omitempty"`
+
+	CoastieSpec `json:",inline"`
+}
+
+// ClusterCoastieStatus defines the observed state of ClusterCoastie
+// +k8s:openapi-gen=true
+type ClusterCoastieStatus struct {
+	TestNamespace string `json:"testnamespace,omitempty"`
+
+	CoastieStatus `json:",inline"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCoastie is the Schema for the clustercoasties API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +genclient:nonNamespaced
+type ClusterCoastie struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterCoastieSpec   `json:"spec,omitempty"`
+	Status ClusterCoastieStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCoastieList contains a list of ClusterCoastie
+type ClusterCoastieList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterCoastie `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCoastie{}, &ClusterCoastieList{})
+}
@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	resource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -13,12 +14,313 @@ type CoastieSpec struct {
 	SlackChannelID string   `json:"slackchannelid"`
 	SlackToken     string   `json:"slacktoken"`
 	HostURL        string   `json:"hosturl"`
+	// RunHistory is the retention policy of the CoastieRuns recorded for every test execution
+	RunHistory *RunHistory `json:"runhistory,omitempty"`
+	// SLO enables availability and error budget reporting per test, computed from its CoastieRuns
+	SLO *SLO `json:"slo,omitempty"`
+	// TestSettings tune every test by name, for example tcp
+	TestSettings map[string]TestSettings `json:"testsettings,omitempty"`
+}
+
+// TestSettings tune a single test
+// +k8s:openapi-gen=true
+type TestSettings struct {
+	// Retry bounds how long the test, and its cleanup, is retried
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Timeouts of the rollout and probes of the test
+	Timeouts *TestTimeouts `json:"timeouts,omitempty"`
+	// TcpUdp customizes what the tcp and udp tests send and expect back
+	TcpUdp *TcpUdpProbe `json:"tcpudp,omitempty"`
+	// HTTP customizes the request the http test sends through the Ingress, and the response it expects
+	HTTP *HTTPProbe `json:"http,omitempty"`
+	// Latency thresholds degrade or fail the test on the round trip time of its probes
+	Latency *LatencyThresholds `json:"latency,omitempty"`
+	// Bandwidth tunes the transfers of the bandwidth test
+	Bandwidth *BandwidthTest `json:"bandwidth,omitempty"`
+	// MTU tunes the packet sizes of the mtu test
+	MTU *MTUTest `json:"mtu,omitempty"`
+	// Burst measures the packet loss, reordering and jitter of the udp test
+	Burst *UDPBurst `json:"burst,omitempty"`
+	// IPFamilies the tcp, udp and http tests run over, IPv4, IPv6 or both. The Service of the test is created
+	// with these families, and every family is probed separately. Defaults to the family of the cluster
+	IPFamilies []string `json:"ipfamilies,omitempty"`
+	// LoadBalancing tunes the requests of the loadbalancing test
+	LoadBalancing *LoadBalancingTest `json:"loadbalancing,omitempty"`
+	// Expose has the tcp and udp tests probe their servers through a NodePort or LoadBalancer Service too
+	Expose *ExposeTest `json:"expose,omitempty"`
+	// Endpoints tunes the thresholds of the endpoints test
+	Endpoints *EndpointsTest `json:"endpoints,omitempty"`
+	// NetworkPolicy tunes the connections of the networkpolicy test
+	NetworkPolicy *NetworkPolicyTest `json:"networkpolicy,omitempty"`
+	// Egress lists the external targets of the egress test
+	Egress *EgressTest `json:"egress,omitempty"`
+	// GRPC tunes the health checks of the grpc test
+	GRPC *GRPCTest `json:"grpc,omitempty"`
+	// WebSocket tunes how long the websocket test holds its connections
+	WebSocket *WebSocketTest `json:"websocket,omitempty"`
+}
+
+// WebSocketTest tunes the websocket test, which holds a WebSocket to the agents open through their Service and
+// Ingress, and reports connections dropped before the end
+// +k8s:openapi-gen=true
+type WebSocketTest struct {
+	// Duration every connection is held for, defaults to 1m
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// PingInterval between two messages echoed by the agent, defaults to 10s
+	PingInterval *metav1.Duration `json:"pinginterval,omitempty"`
+}
+
+// GRPCTest tunes the grpc test, which calls grpc.health.v1.Health/Check through the ClusterIP of the agents, or
+// a configured target, and through the Ingress
+// +k8s:openapi-gen=true
+type GRPCTest struct {
+	// Target is the host:port of a gRPC service checked instead of the ClusterIP of the agents, without TLS
+	Target string `json:"target,omitempty"`
+	// Service is the name of the service of the target whose health is checked, the server as a whole when empty.
+	// The agents only know the server as a whole
+	Service string `json:"service,omitempty"`
+	// InsecureSkipVerify skips the verification of the certificate of the Ingress
+	InsecureSkipVerify bool `json:"insecureskipverify,omitempty"`
+}
+
+// EgressTest lists the targets outside of the cluster the agent on every node must reach, revealing nodes with
+// broken NAT, proxy or firewall egress
+// +k8s:openapi-gen=true
+type EgressTest struct {
+	// Targets probed from every node
+	Targets []EgressTarget `json:"targets,omitempty"`
+	// Timeout of every probe of a target, defaults to 5s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// EgressTarget is an endpoint outside of the cluster probed by the egress test
+// +k8s:openapi-gen=true
+type EgressTarget struct {
+	// Name of the target in the node results, defaults to its address
+	Name string `json:"name,omitempty"`
+	// Type of the probe: tcp connects to the address, http sends a GET to it and dns resolves it
+	Type string `json:"type"`
+	// Address is a host:port for tcp, a URL for http and a host name for dns
+	Address string `json:"address"`
+	// StatusCodes expected from an http target, any status passes when empty
+	StatusCodes []int `json:"statuscodes,omitempty"`
+}
+
+// NetworkPolicyTest tunes the networkpolicy test, in which agents allowed by a NetworkPolicy must reach the
+// agent servers, and agents denied by it must not
+// +k8s:openapi-gen=true
+type NetworkPolicyTest struct {
+	// Timeout of every connection, a denied connection still pending after it counts as blocked. Defaults to 2s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// EndpointsTest tunes the endpoints test, which measures how long the agent pods take, once Ready, to be added
+// to the endpoints of their Service and to answer through its ClusterIP
+// +k8s:openapi-gen=true
+type EndpointsTest struct {
+	// MaxEndpointDelay fails the test when a pod is added to the endpoints later than this after becoming Ready,
+	// defaults to 5s
+	MaxEndpointDelay *metav1.Duration `json:"maxendpointdelay,omitempty"`
+	// MaxAnswerDelay fails the test when a pod answers through the ClusterIP later than this after becoming
+	// Ready, defaults to 10s
+	MaxAnswerDelay *metav1.Duration `json:"maxanswerdelay,omitempty"`
+}
+
+// ExposeTest has the tcp or udp test create a NodePort or LoadBalancer Service in front of its servers, besides
+// the ClusterIP one, and probe them
+// +k8s:openapi-gen=true
+type ExposeTest struct {
+	// NodePort probes the port allocated to the NodePort Service on the IP of every node
+	NodePort bool `json:"nodeport,omitempty"`
+	// LoadBalancer waits for the LoadBalancer Service to get an external IP, and probes it
+	LoadBalancer bool `json:"loadbalancer,omitempty"`
+	// LoadBalancerTimeout is how long the LoadBalancer Service has to get an external IP, defaults to 5m
+	LoadBalancerTimeout *metav1.Duration `json:"loadbalancertimeout,omitempty"`
+}
+
+// LoadBalancingTest tunes the loadbalancing test, which sends requests through the Service and Ingress of the
+// agents and counts the requests every agent answered
+// +k8s:openapi-gen=true
+type LoadBalancingTest struct {
+	// Requests sent through the ClusterIP, and through the Ingress, defaults to 10 per endpoint and at least 100
+	Requests int `json:"requests,omitempty"`
+}
+
+// UDPBurst has the udp test send a burst of sequenced datagrams to the pod on every node, which runs the agent
+// alongside the udp server to echo them
+// +k8s:openapi-gen=true
+type UDPBurst struct {
+	// Count of datagrams in the burst, defaults to 100
+	Count int `json:"count,omitempty"`
+	// Interval between two datagrams, defaults to 10ms
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Size of every datagram in bytes, at least 16, defaults to 64
+	Size int `json:"size,omitempty"`
+	// MaxLossPercent fails the test when a larger share of the burst to a node is lost, for example "0.5".
+	// Defaults to "1"
+	MaxLossPercent string `json:"maxlosspercent,omitempty"`
+}
+
+// MTUTest tunes the mtu test, in which the agent on a node searches the largest UDP packet, sent with the don't
+// fragment bit set, and TCP payload echoed by the agent on another node
+// +k8s:openapi-gen=true
+type MTUTest struct {
+	// ExpectedMTU fails the test when the path MTU between a pair of nodes is below it, defaults to the MTU of
+	// the interface of the sending pod
+	ExpectedMTU int `json:"expectedmtu,omitempty"`
+	// Mode is sampled or fullmesh, like the mode of the bandwidth test. Defaults to sampled
+	Mode string `json:"mode,omitempty"`
+	// Timeout waiting for every packet to be echoed, defaults to 1s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// BandwidthTest tunes the bandwidth test, in which the agent on a node sends data over TCP to the agent on
+// another node
+// +k8s:openapi-gen=true
+type BandwidthTest struct {
+	// Bytes sent between every pair of nodes, defaults to 64Mi
+	Bytes *resource.Quantity `json:"bytes,omitempty"`
+	// Mode is sampled, every node sends to one other node and receives from another, or fullmesh, every node
+	// sends to every other node. Defaults to sampled
+	Mode string `json:"mode,omitempty"`
+	// MinMbps fails the test when the throughput between a pair of nodes is below it, in megabits per second
+	MinMbps int `json:"minmbps,omitempty"`
+	// Timeout of the transfer between a pair of nodes, defaults to 1m
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// LatencyThresholds degrade or fail a test whose probes passed too slowly. The round trip time of the probe
+// through the Service or Ingress and of the probe of every node are measured, and their percentile is compared
+// to the thresholds
+// +k8s:openapi-gen=true
+type LatencyThresholds struct {
+	// Percentile of the probe latencies compared to the thresholds, between 1 and 100, defaults to 95
+	Percentile int `json:"percentile,omitempty"`
+	// Warn marks the test Degraded when the percentile latency exceeds it
+	Warn *metav1.Duration `json:"warn,omitempty"`
+	// Fail marks the test Failed when the percentile latency exceeds it
+	Fail *metav1.Duration `json:"fail,omitempty"`
+}
+
+// HTTPProbe customizes the request the http test sends to the hosturl, so it can validate real application routes.
+// The probes sent directly to the pod on every node keep checking the bundled http server
+// +k8s:openapi-gen=true
+type HTTPProbe struct {
+	// Scheme is http or https, defaults to http
+	Scheme string `json:"scheme,omitempty"`
+	// Path of the request, defaults to /ruok
+	Path string `json:"path,omitempty"`
+	// Method of the request, defaults to GET
+	Method string `json:"method,omitempty"`
+	// Headers sent with the request, a Host header overrides the host of the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body sent with the request
+	Body string `json:"body,omitempty"`
+	// StatusCodes are the status codes expected, defaults to 200
+	StatusCodes []int `json:"statuscodes,omitempty"`
+	// BodyContains is a substring the response body must contain
+	BodyContains string `json:"bodycontains,omitempty"`
+	// BodyRegex is a regular expression the response body must match
+	BodyRegex string `json:"bodyregex,omitempty"`
+	// JSONPath assertions on the response body, which must be JSON
+	JSONPath []JSONPathAssertion `json:"jsonpath,omitempty"`
+	// FollowRedirects follows redirects when true, defaults to true
+	FollowRedirects *bool `json:"followredirects,omitempty"`
+	// MaxRedirects is the number of redirects followed, defaults to 10
+	MaxRedirects int `json:"maxredirects,omitempty"`
+	// InsecureSkipVerify skips the verification of the certificate of https requests
+	InsecureSkipVerify bool `json:"insecureskipverify,omitempty"`
+}
+
+// JSONPathAssertion asserts on the value found at a JSONPath of a JSON response body
+// +k8s:openapi-gen=true
+type JSONPathAssertion struct {
+	// Path is a kubectl style JSONPath template, for example {.status}
+	Path string `json:"path"`
+	// Value is the expected result of Path, which only has to exist when Value is empty
+	Value string `json:"value,omitempty"`
+}
+
+// TcpUdpProbe customizes the probes of the tcp and udp tests, so they can test other servers and protocols than
+// the bundled echo servers
+// +k8s:openapi-gen=true
+type TcpUdpProbe struct {
+	// Host is probed instead of the bundled echo servers when set, no DaemonSet or Service is created
+	Host string `json:"host,omitempty"`
+	// Port of Host, defaults to the port of the bundled echo server
+	Port int32 `json:"port,omitempty"`
+	// Payload sent once connected, defaults to the question of the bundled echo server
+	Payload string `json:"payload,omitempty"`
+	// Encoding of Payload and Expect value, text or base64, defaults to text
+	Encoding string `json:"encoding,omitempty"`
+	// Expect is the response the probe expects. Defaults to the answer of the bundled echo server when Payload
+	// is not set, and to any response otherwise
+	Expect *ResponseMatch `json:"expect,omitempty"`
+}
+
+// ResponseMatch is a response a probe expects
+// +k8s:openapi-gen=true
+type ResponseMatch struct {
+	// Match is exact, prefix or regex, defaults to exact
+	Match string `json:"match,omitempty"`
+	Value string `json:"value"`
+}
+
+// TestTimeouts tune how long a test waits on its DaemonSet and its probes
+// +k8s:openapi-gen=true
+type TestTimeouts struct {
+	// RolloutDeadline is how long the DaemonSet has to become ready, defaults to the rollouttimeout of the operator
+	RolloutDeadline *metav1.Duration `json:"rolloutdeadline,omitempty"`
+	// ProbeAttempts is the number of probes through the Service or Ingress before the test fails, defaults to 5
+	ProbeAttempts int `json:"probeattempts,omitempty"`
+	// ProbeInterval is the wait between two probe attempts, defaults to 2s for tcp and udp, and 6s for http
+	ProbeInterval *metav1.Duration `json:"probeinterval,omitempty"`
+	// DialTimeout is how long a probe waits to connect, defaults to 10s
+	DialTimeout *metav1.Duration `json:"dialtimeout,omitempty"`
+	// ReadTimeout is how long a probe waits for the response once connected, defaults to 2s for tcp and udp,
+	// and 10s for http
+	ReadTimeout *metav1.Duration `json:"readtimeout,omitempty"`
+}
+
+// RetryPolicy bounds the retries of a test. Errors and failed probes count as failed attempts and are retried
+// after an exponential backoff, the test is marked Failed and alerted on once the policy is exhausted
+// +k8s:openapi-gen=true
+type RetryPolicy struct {
+	// MaxAttempts is the number of failed attempts before giving up, defaults to 5
+	MaxAttempts int `json:"maxattempts,omitempty"`
+	// InitialBackoff is the wait after the first failed attempt, doubled after every other one, defaults to 5s
+	InitialBackoff *metav1.Duration `json:"initialbackoff,omitempty"`
+	// MaxBackoff caps the wait between two attempts, defaults to 1m
+	MaxBackoff *metav1.Duration `json:"maxbackoff,omitempty"`
+	// Deadline is how long the test is retried for in total, defaults to 30m
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+}
+
+// RunHistory controls how many CoastieRuns are kept per test, whichever limit is hit first wins
+// +k8s:openapi-gen=true
+type RunHistory struct {
+	// Limit is the number of CoastieRuns kept per test, defaults to 100
+	Limit int `json:"limit,omitempty"`
+	// MaxAge is how long CoastieRuns are kept, defaults to 720h
+	MaxAge *metav1.Duration `json:"maxage,omitempty"`
+}
+
+// SLO is the service level objective every test of a Coastie is measured against
+// +k8s:openapi-gen=true
+type SLO struct {
+	// Objective is the availability target in percent, for example "99.9"
+	Objective string `json:"objective"`
+	// Windows are the rolling windows availability is computed over, defaults to 1h, 24h and 720h
+	Windows []metav1.Duration `json:"windows,omitempty"`
+	// BurnRateThreshold alerts when the error budget burn rate over any window exceeds it, for example "14.4".
+	// Leave empty to disable alerting
+	BurnRateThreshold string `json:"burnratethreshold,omitempty"`
 }
 
 // CoastieStatus defines the observed state of Coastie
 // +k8s:openapi-gen=true
 type CoastieStatus struct {
-	TestResults map[string]TestResult `json"testresults"`
+	TestResults map[string]TestResult `json:"testresults,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -44,8 +346,55 @@ type CoastieList struct {
 }
 
 type TestResult struct {
+	// Status is Running, Passed, Degraded or Failed
 	Status                string `json:"status,omitempty"`
 	DaemonSetCreationTime string `json:"daemonsetcreationtime,omitempty"`
+	// LastRun is the name of the CoastieRun recorded for the latest execution
+	LastRun string `json:"lastrun,omitempty"`
+	// Availability of the test over every SLO window
+	Availability []WindowAvailability `json:"availability,omitempty"`
+	// Latency is the percentile round trip time of the probes of the latest execution
+	Latency *metav1.Duration `json:"latency,omitempty"`
+	// Conditions of the test, Available and Degraded
+	Conditions []TestCondition `json:"conditions,omitempty"`
+	// LoadBalancerAllocation is how long the LoadBalancer Service of the latest execution took to get an
+	// external IP
+	LoadBalancerAllocation *metav1.Duration `json:"loadbalancerallocation,omitempty"`
+}
+
+// TestCondition is an aspect of the latest result of a test
+type TestCondition struct {
+	// Type is Available or Degraded
+	Type string `json:"type"`
+	// Status is True or False
+	Status string `json:"status"`
+	// LastTransitionTime is when Status last changed
+	LastTransitionTime metav1.Time `json:"lasttransitiontime,omitempty"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+}
+
+// WindowAvailability is the availability of a test over a rolling window
+type WindowAvailability struct {
+	Window metav1.Duration `json:"window"`
+	// Availability is the percentage of passed runs within the window
+	Availability string `json:"availability"`
+	Runs         int    `json:"runs"`
+	FailedRuns   int    `json:"failedruns"`
+	// BurnRate is how fast the error budget is consumed, 1 uses exactly the budget over the window
+	BurnRate string `json:"burnrate,omitempty"`
+	// Alerting is true while the burn rate exceeds the BurnRateThreshold
+	Alerting bool `json:"alerting,omitempty"`
+	// Buckets count the runs ended within the window, oldest first, so the availability does not depend on the
+	// CoastieRuns kept
+	Buckets []RunBucket `json:"buckets,omitempty"`
+}
+
+// RunBucket counts the runs ended within a slice of an availability window
+type RunBucket struct {
+	Start      metav1.Time `json:"start"`
+	Runs       int         `json:"runs"`
+	FailedRuns int         `json:"failedruns"`
 }
 
 func init() {
@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels set on every CoastieRun, so the runs of a Coastie and test can be listed
+const (
+	CoastieRunCoastieLabel = "k8s.soh.re/coastie"
+	CoastieRunTestLabel    = "k8s.soh.re/test"
+)
+
+// CoastieRunSpec defines which Coastie and test a CoastieRun was recorded for
+// +k8s:openapi-gen=true
+type CoastieRunSpec struct {
+	Coastie string `json:"coastie"`
+	Test    string `json:"test"`
+}
+
+// CoastieRunStatus holds the outcome of a single test execution
+// +k8s:openapi-gen=true
+type CoastieRunStatus struct {
+	StartTime metav1.Time `json:"starttime,omitempty"`
+	EndTime   metav1.Time `json:"endtime,omitempty"`
+	// Result is Passed, Degraded or Failed
+	Result string `json:"result,omitempty"`
+	// Latency is the percentile round trip time of the probes of the run
+	Latency *metav1.Duration `json:"latency,omitempty"`
+	// Message holds the failure diagnostics of the run
+	Message string       `json:"message,omitempty"`
+	Nodes   []NodeResult `json:"nodes,omitempty"`
+}
+
+// NodeResult is the outcome of a test on a single node
+// +k8s:openapi-gen=true
+type NodeResult struct {
+	NodeName string `json:"nodename"`
+	PodName  string `json:"podname,omitempty"`
+	Status   string `json:"status"`
+	// Latency is the round trip time of the probe against the pod on this node
+	Latency *metav1.Duration `json:"latency,omitempty"`
+	// StartupLatency is the time between the DaemonSet creation and the pod becoming ready
+	StartupLatency *metav1.Duration `json:"startuplatency,omitempty"`
+	Message        string           `json:"message,omitempty"`
+	// Target is the node the probe of this node was sent to, for tests between pairs of nodes
+	Target string `json:"target,omitempty"`
+	// Metrics are the measurements specific to the test, for example the throughput of the bandwidth test
+	Metrics map[string]string `json:"metrics,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CoastieRun is the Schema for the coastieruns API, one is recorded per test execution
+// +k8s:openapi-gen=true
+type CoastieRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CoastieRunSpec   `json:"spec,omitempty"`
+	Status CoastieRunStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CoastieRunList contains a list of CoastieRun
+type CoastieRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CoastieRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CoastieRun{}, &CoastieRunList{})
+}
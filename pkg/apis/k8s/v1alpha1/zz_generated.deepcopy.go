@@ -5,9 +5,139 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BandwidthTest) DeepCopyInto(out *BandwidthTest) {
+	*out = *in
+	if in.Bytes != nil {
+		in, out := &in.Bytes, &out.Bytes
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BandwidthTest.
+func (in *BandwidthTest) DeepCopy() *BandwidthTest {
+	if in == nil {
+		return nil
+	}
+	out := new(BandwidthTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCoastie) DeepCopyInto(out *ClusterCoastie) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCoastie.
+func (in *ClusterCoastie) DeepCopy() *ClusterCoastie {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCoastie)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterCoastie) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCoastieList) DeepCopyInto(out *ClusterCoastieList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterCoastie, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCoastieList.
+func (in *ClusterCoastieList) DeepCopy() *ClusterCoastieList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCoastieList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterCoastieList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCoastieSpec) DeepCopyInto(out *ClusterCoastieSpec) {
+	*out = *in
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	in.CoastieSpec.DeepCopyInto(&out.CoastieSpec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCoastieSpec.
+func (in *ClusterCoastieSpec) DeepCopy() *ClusterCoastieSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCoastieSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterCoastieStatus) DeepCopyInto(out *ClusterCoastieStatus) {
+	*out = *in
+	in.CoastieStatus.DeepCopyInto(&out.CoastieStatus)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCoastieStatus.
+func (in *ClusterCoastieStatus) DeepCopy() *ClusterCoastieStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterCoastieStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Coastie) DeepCopyInto(out *Coastie) {
 	*out = *in
@@ -69,6 +199,113 @@ func (in *CoastieList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoastieRun) DeepCopyInto(out *CoastieRun) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoastieRun.
+func (in *CoastieRun) DeepCopy() *CoastieRun {
+	if in == nil {
+		return nil
+	}
+	out := new(CoastieRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CoastieRun) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoastieRunList) DeepCopyInto(out *CoastieRunList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CoastieRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoastieRunList.
+func (in *CoastieRunList) DeepCopy() *CoastieRunList {
+	if in == nil {
+		return nil
+	}
+	out := new(CoastieRunList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CoastieRunList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoastieRunSpec) DeepCopyInto(out *CoastieRunSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoastieRunSpec.
+func (in *CoastieRunSpec) DeepCopy() *CoastieRunSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CoastieRunSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoastieRunStatus) DeepCopyInto(out *CoastieRunStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.EndTime.DeepCopyInto(&out.EndTime)
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoastieRunStatus.
+func (in *CoastieRunStatus) DeepCopy() *CoastieRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CoastieRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoastieSpec) DeepCopyInto(out *CoastieSpec) {
 	*out = *in
@@ -77,6 +314,23 @@ func (in *CoastieSpec) DeepCopyInto(out *CoastieSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RunHistory != nil {
+		in, out := &in.RunHistory, &out.RunHistory
+		*out = new(RunHistory)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SLO != nil {
+		in, out := &in.SLO, &out.SLO
+		*out = new(SLO)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TestSettings != nil {
+		in, out := &in.TestSettings, &out.TestSettings
+		*out = make(map[string]TestSettings, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	return
 }
 
@@ -97,7 +351,7 @@ func (in *CoastieStatus) DeepCopyInto(out *CoastieStatus) {
 		in, out := &in.TestResults, &out.TestResults
 		*out = make(map[string]TestResult, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 	return
@@ -114,17 +368,671 @@ func (in *CoastieStatus) DeepCopy() *CoastieStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TestResult) DeepCopyInto(out *TestResult) {
+func (in *EgressTarget) DeepCopyInto(out *EgressTarget) {
 	*out = *in
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestResult.
-func (in *TestResult) DeepCopy() *TestResult {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressTarget.
+func (in *EgressTarget) DeepCopy() *EgressTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(TestResult)
+	out := new(EgressTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressTest) DeepCopyInto(out *EgressTest) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]EgressTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressTest.
+func (in *EgressTest) DeepCopy() *EgressTest {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointsTest) DeepCopyInto(out *EndpointsTest) {
+	*out = *in
+	if in.MaxEndpointDelay != nil {
+		in, out := &in.MaxEndpointDelay, &out.MaxEndpointDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxAnswerDelay != nil {
+		in, out := &in.MaxAnswerDelay, &out.MaxAnswerDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointsTest.
+func (in *EndpointsTest) DeepCopy() *EndpointsTest {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointsTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExposeTest) DeepCopyInto(out *ExposeTest) {
+	*out = *in
+	if in.LoadBalancerTimeout != nil {
+		in, out := &in.LoadBalancerTimeout, &out.LoadBalancerTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExposeTest.
+func (in *ExposeTest) DeepCopy() *ExposeTest {
+	if in == nil {
+		return nil
+	}
+	out := new(ExposeTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCTest) DeepCopyInto(out *GRPCTest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCTest.
+func (in *GRPCTest) DeepCopy() *GRPCTest {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPProbe) DeepCopyInto(out *HTTPProbe) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.JSONPath != nil {
+		in, out := &in.JSONPath, &out.JSONPath
+		*out = make([]JSONPathAssertion, len(*in))
+		copy(*out, *in)
+	}
+	if in.FollowRedirects != nil {
+		in, out := &in.FollowRedirects, &out.FollowRedirects
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPProbe.
+func (in *HTTPProbe) DeepCopy() *HTTPProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JSONPathAssertion) DeepCopyInto(out *JSONPathAssertion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JSONPathAssertion.
+func (in *JSONPathAssertion) DeepCopy() *JSONPathAssertion {
+	if in == nil {
+		return nil
+	}
+	out := new(JSONPathAssertion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyThresholds) DeepCopyInto(out *LatencyThresholds) {
+	*out = *in
+	if in.Warn != nil {
+		in, out := &in.Warn, &out.Warn
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Fail != nil {
+		in, out := &in.Fail, &out.Fail
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyThresholds.
+func (in *LatencyThresholds) DeepCopy() *LatencyThresholds {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyThresholds)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancingTest) DeepCopyInto(out *LoadBalancingTest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancingTest.
+func (in *LoadBalancingTest) DeepCopy() *LoadBalancingTest {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancingTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MTUTest) DeepCopyInto(out *MTUTest) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MTUTest.
+func (in *MTUTest) DeepCopy() *MTUTest {
+	if in == nil {
+		return nil
+	}
+	out := new(MTUTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyTest) DeepCopyInto(out *NetworkPolicyTest) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyTest.
+func (in *NetworkPolicyTest) DeepCopy() *NetworkPolicyTest {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeResult) DeepCopyInto(out *NodeResult) {
+	*out = *in
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.StartupLatency != nil {
+		in, out := &in.StartupLatency, &out.StartupLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeResult.
+func (in *NodeResult) DeepCopy() *NodeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResponseMatch) DeepCopyInto(out *ResponseMatch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResponseMatch.
+func (in *ResponseMatch) DeepCopy() *ResponseMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ResponseMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.InitialBackoff != nil {
+		in, out := &in.InitialBackoff, &out.InitialBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxBackoff != nil {
+		in, out := &in.MaxBackoff, &out.MaxBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunBucket) DeepCopyInto(out *RunBucket) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunBucket.
+func (in *RunBucket) DeepCopy() *RunBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(RunBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunHistory) DeepCopyInto(out *RunHistory) {
+	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunHistory.
+func (in *RunHistory) DeepCopy() *RunHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(RunHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLO) DeepCopyInto(out *SLO) {
+	*out = *in
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]v1.Duration, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLO.
+func (in *SLO) DeepCopy() *SLO {
+	if in == nil {
+		return nil
+	}
+	out := new(SLO)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TcpUdpProbe) DeepCopyInto(out *TcpUdpProbe) {
+	*out = *in
+	if in.Expect != nil {
+		in, out := &in.Expect, &out.Expect
+		*out = new(ResponseMatch)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TcpUdpProbe.
+func (in *TcpUdpProbe) DeepCopy() *TcpUdpProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(TcpUdpProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestCondition) DeepCopyInto(out *TestCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestCondition.
+func (in *TestCondition) DeepCopy() *TestCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(TestCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestResult) DeepCopyInto(out *TestResult) {
+	*out = *in
+	if in.Availability != nil {
+		in, out := &in.Availability, &out.Availability
+		*out = make([]WindowAvailability, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]TestCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LoadBalancerAllocation != nil {
+		in, out := &in.LoadBalancerAllocation, &out.LoadBalancerAllocation
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestResult.
+func (in *TestResult) DeepCopy() *TestResult {
+	if in == nil {
+		return nil
+	}
+	out := new(TestResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestSettings) DeepCopyInto(out *TestSettings) {
+	*out = *in
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(TestTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TcpUdp != nil {
+		in, out := &in.TcpUdp, &out.TcpUdp
+		*out = new(TcpUdpProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyThresholds)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Bandwidth != nil {
+		in, out := &in.Bandwidth, &out.Bandwidth
+		*out = new(BandwidthTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MTU != nil {
+		in, out := &in.MTU, &out.MTU
+		*out = new(MTUTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(UDPBurst)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancing != nil {
+		in, out := &in.LoadBalancing, &out.LoadBalancing
+		*out = new(LoadBalancingTest)
+		**out = **in
+	}
+	if in.Expose != nil {
+		in, out := &in.Expose, &out.Expose
+		*out = new(ExposeTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(EndpointsTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicyTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = new(EgressTest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(GRPCTest)
+		**out = **in
+	}
+	if in.WebSocket != nil {
+		in, out := &in.WebSocket, &out.WebSocket
+		*out = new(WebSocketTest)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestSettings.
+func (in *TestSettings) DeepCopy() *TestSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(TestSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestTimeouts) DeepCopyInto(out *TestTimeouts) {
+	*out = *in
+	if in.RolloutDeadline != nil {
+		in, out := &in.RolloutDeadline, &out.RolloutDeadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ProbeInterval != nil {
+		in, out := &in.ProbeInterval, &out.ProbeInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DialTimeout != nil {
+		in, out := &in.DialTimeout, &out.DialTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ReadTimeout != nil {
+		in, out := &in.ReadTimeout, &out.ReadTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestTimeouts.
+func (in *TestTimeouts) DeepCopy() *TestTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(TestTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UDPBurst) DeepCopyInto(out *UDPBurst) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UDPBurst.
+func (in *UDPBurst) DeepCopy() *UDPBurst {
+	if in == nil {
+		return nil
+	}
+	out := new(UDPBurst)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebSocketTest) DeepCopyInto(out *WebSocketTest) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PingInterval != nil {
+		in, out := &in.PingInterval, &out.PingInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebSocketTest.
+func (in *WebSocketTest) DeepCopy() *WebSocketTest {
+	if in == nil {
+		return nil
+	}
+	out := new(WebSocketTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowAvailability) DeepCopyInto(out *WindowAvailability) {
+	*out = *in
+	out.Window = in.Window
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]RunBucket, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowAvailability.
+func (in *WindowAvailability) DeepCopy() *WindowAvailability {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowAvailability)
 	in.DeepCopyInto(out)
 	return out
 }
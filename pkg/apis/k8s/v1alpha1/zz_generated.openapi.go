@@ -13,9 +13,251 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.Coastie":       schema_pkg_apis_k8s_v1alpha1_Coastie(ref),
-		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieSpec":   schema_pkg_apis_k8s_v1alpha1_CoastieSpec(ref),
-		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieStatus": schema_pkg_apis_k8s_v1alpha1_CoastieStatus(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.BandwidthTest":        schema_pkg_apis_k8s_v1alpha1_BandwidthTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastie":       schema_pkg_apis_k8s_v1alpha1_ClusterCoastie(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieSpec":   schema_pkg_apis_k8s_v1alpha1_ClusterCoastieSpec(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieStatus": schema_pkg_apis_k8s_v1alpha1_ClusterCoastieStatus(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.Coastie":              schema_pkg_apis_k8s_v1alpha1_Coastie(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRun":           schema_pkg_apis_k8s_v1alpha1_CoastieRun(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunSpec":       schema_pkg_apis_k8s_v1alpha1_CoastieRunSpec(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunStatus":     schema_pkg_apis_k8s_v1alpha1_CoastieRunStatus(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieSpec":          schema_pkg_apis_k8s_v1alpha1_CoastieSpec(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieStatus":        schema_pkg_apis_k8s_v1alpha1_CoastieStatus(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTarget":         schema_pkg_apis_k8s_v1alpha1_EgressTarget(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTest":           schema_pkg_apis_k8s_v1alpha1_EgressTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EndpointsTest":        schema_pkg_apis_k8s_v1alpha1_EndpointsTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ExposeTest":           schema_pkg_apis_k8s_v1alpha1_ExposeTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.GRPCTest":             schema_pkg_apis_k8s_v1alpha1_GRPCTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.HTTPProbe":            schema_pkg_apis_k8s_v1alpha1_HTTPProbe(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.JSONPathAssertion":    schema_pkg_apis_k8s_v1alpha1_JSONPathAssertion(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LatencyThresholds":    schema_pkg_apis_k8s_v1alpha1_LatencyThresholds(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LoadBalancingTest":    schema_pkg_apis_k8s_v1alpha1_LoadBalancingTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.MTUTest":              schema_pkg_apis_k8s_v1alpha1_MTUTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NetworkPolicyTest":    schema_pkg_apis_k8s_v1alpha1_NetworkPolicyTest(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NodeResult":           schema_pkg_apis_k8s_v1alpha1_NodeResult(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ResponseMatch":        schema_pkg_apis_k8s_v1alpha1_ResponseMatch(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RetryPolicy":          schema_pkg_apis_k8s_v1alpha1_RetryPolicy(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RunHistory":           schema_pkg_apis_k8s_v1alpha1_RunHistory(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.SLO":                  schema_pkg_apis_k8s_v1alpha1_SLO(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TcpUdpProbe":          schema_pkg_apis_k8s_v1alpha1_TcpUdpProbe(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestSettings":         schema_pkg_apis_k8s_v1alpha1_TestSettings(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestTimeouts":         schema_pkg_apis_k8s_v1alpha1_TestTimeouts(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.UDPBurst":             schema_pkg_apis_k8s_v1alpha1_UDPBurst(ref),
+		"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.WebSocketTest":        schema_pkg_apis_k8s_v1alpha1_WebSocketTest(ref),
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_BandwidthTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BandwidthTest tunes the bandwidth test, in which the agent on a node sends data over TCP to the agent on another node",
+				Properties: map[string]spec.Schema{
+					"bytes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Bytes sent between every pair of nodes, defaults to 64Mi",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Mode is sampled, every node sends to one other node and receives from another, or fullmesh, every node sends to every other node. Defaults to sampled",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"minmbps": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MinMbps fails the test when the throughput between a pair of nodes is below it, in megabits per second",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout of the transfer between a pair of nodes, defaults to 1m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_ClusterCoastie(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ClusterCoastie is the Schema for the clustercoasties API",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieSpec", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ClusterCoastieStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_ClusterCoastieSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ClusterCoastieSpec defines the desired state of ClusterCoastie",
+				Properties: map[string]spec.Schema{
+					"testnamespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TestNamespace is the namespace created and owned by the ClusterCoastie to run its tests in. Defaults to coastie-<name>",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"nodeselector": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeSelector is set as the node-selector annotation of the test namespace. Leave empty so the test DaemonSets are allowed on every node",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"quota": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Quota overrides the ResourceQuota that is otherwise sized from the number of nodes and tests",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+									},
+								},
+							},
+						},
+					},
+					"tests": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"slackchannelid": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"slacktoken": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"hosturl": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"runhistory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunHistory is the retention policy of the CoastieRuns recorded for every test execution",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RunHistory"),
+						},
+					},
+					"slo": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SLO enables availability and error budget reporting per test, computed from its CoastieRuns",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.SLO"),
+						},
+					},
+					"testsettings": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TestSettings tune every test by name, for example tcp",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestSettings"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"tests", "slackchannelid", "slacktoken", "hosturl"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RunHistory", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.SLO", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestSettings", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_ClusterCoastieStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ClusterCoastieStatus defines the observed state of ClusterCoastie",
+				Properties: map[string]spec.Schema{
+					"testnamespace": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"testresults": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestResult"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestResult"},
 	}
 }
 
@@ -62,15 +304,200 @@ func schema_pkg_apis_k8s_v1alpha1_Coastie(ref common.ReferenceCallback) common.O
 	}
 }
 
+func schema_pkg_apis_k8s_v1alpha1_CoastieRun(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CoastieRun is the Schema for the coastieruns API, one is recorded per test execution",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunSpec", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.CoastieRunStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_CoastieRunSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CoastieRunSpec defines which Coastie and test a CoastieRun was recorded for",
+				Properties: map[string]spec.Schema{
+					"coastie": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"test": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+				Required: []string{"coastie", "test"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_CoastieRunStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CoastieRunStatus holds the outcome of a single test execution",
+				Properties: map[string]spec.Schema{
+					"starttime": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"endtime": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"result": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Result is Passed, Degraded or Failed",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"latency": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Latency is the percentile round trip time of the probes of the run",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Message holds the failure diagnostics of the run",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"nodes": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NodeResult"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NodeResult", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
 func schema_pkg_apis_k8s_v1alpha1_CoastieSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
 				Description: "CoastieSpec defines the desired state of Coastie",
-				Properties:  map[string]spec.Schema{},
+				Properties: map[string]spec.Schema{
+					"tests": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"slackchannelid": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"slacktoken": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"hosturl": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"runhistory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunHistory is the retention policy of the CoastieRuns recorded for every test execution",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RunHistory"),
+						},
+					},
+					"slo": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SLO enables availability and error budget reporting per test, computed from its CoastieRuns",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.SLO"),
+						},
+					},
+					"testsettings": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TestSettings tune every test by name, for example tcp",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestSettings"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"tests", "slackchannelid", "slacktoken", "hosturl"},
 			},
 		},
-		Dependencies: []string{},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RunHistory", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.SLO", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestSettings"},
 	}
 }
 
@@ -79,9 +506,937 @@ func schema_pkg_apis_k8s_v1alpha1_CoastieStatus(ref common.ReferenceCallback) co
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
 				Description: "CoastieStatus defines the observed state of Coastie",
-				Properties:  map[string]spec.Schema{},
+				Properties: map[string]spec.Schema{
+					"testresults": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestResult"),
+									},
+								},
+							},
+						},
+					},
+				},
 			},
 		},
-		Dependencies: []string{},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestResult"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_EgressTarget(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "EgressTarget is an endpoint outside of the cluster probed by the egress test",
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the target in the node results, defaults to its address",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Type of the probe: tcp connects to the address, http sends a GET to it and dns resolves it",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"address": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Address is a host:port for tcp, a URL for http and a host name for dns",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"statuscodes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StatusCodes expected from an http target, any status passes when empty",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"integer"},
+										Format: "int32",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"type", "address"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_EgressTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "EgressTest lists the targets outside of the cluster the agent on every node must reach, revealing nodes with broken NAT, proxy or firewall egress",
+				Properties: map[string]spec.Schema{
+					"targets": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Targets probed from every node",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTarget"),
+									},
+								},
+							},
+						},
+					},
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout of every probe of a target, defaults to 5s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTarget", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_EndpointsTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "EndpointsTest tunes the endpoints test, which measures how long the agent pods take, once Ready, to be added to the endpoints of their Service and to answer through its ClusterIP",
+				Properties: map[string]spec.Schema{
+					"maxendpointdelay": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxEndpointDelay fails the test when a pod is added to the endpoints later than this after becoming Ready, defaults to 5s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"maxanswerdelay": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxAnswerDelay fails the test when a pod answers through the ClusterIP later than this after becoming Ready, defaults to 10s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_ExposeTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ExposeTest has the tcp or udp test create a NodePort or LoadBalancer Service in front of its servers, besides the ClusterIP one, and probe them",
+				Properties: map[string]spec.Schema{
+					"nodeport": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodePort probes the port allocated to the NodePort Service on the IP of every node",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"loadbalancer": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LoadBalancer waits for the LoadBalancer Service to get an external IP, and probes it",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"loadbalancertimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LoadBalancerTimeout is how long the LoadBalancer Service has to get an external IP, defaults to 5m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_GRPCTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "GRPCTest tunes the grpc test, which calls grpc.health.v1.Health/Check through the ClusterIP of the agents, or a configured target, and through the Ingress",
+				Properties: map[string]spec.Schema{
+					"target": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Target is the host:port of a gRPC service checked instead of the ClusterIP of the agents, without TLS",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"service": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Service is the name of the service of the target whose health is checked, the server as a whole when empty. The agents only know the server as a whole",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"insecureskipverify": {
+						SchemaProps: spec.SchemaProps{
+							Description: "InsecureSkipVerify skips the verification of the certificate of the Ingress",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_HTTPProbe(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "HTTPProbe customizes the request the http test sends to the hosturl, so it can validate real application routes. The probes sent directly to the pod on every node keep checking the bundled http server",
+				Properties: map[string]spec.Schema{
+					"scheme": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Scheme is http or https, defaults to http",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path of the request, defaults to /ruok",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"method": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Method of the request, defaults to GET",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"headers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Headers sent with the request, a Host header overrides the host of the request",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"body": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Body sent with the request",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"statuscodes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StatusCodes are the status codes expected, defaults to 200",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"integer"},
+										Format: "int32",
+									},
+								},
+							},
+						},
+					},
+					"bodycontains": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BodyContains is a substring the response body must contain",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"bodyregex": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BodyRegex is a regular expression the response body must match",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"jsonpath": {
+						SchemaProps: spec.SchemaProps{
+							Description: "JSONPath assertions on the response body, which must be JSON",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.JSONPathAssertion"),
+									},
+								},
+							},
+						},
+					},
+					"followredirects": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FollowRedirects follows redirects when true, defaults to true",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"maxredirects": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxRedirects is the number of redirects followed, defaults to 10",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"insecureskipverify": {
+						SchemaProps: spec.SchemaProps{
+							Description: "InsecureSkipVerify skips the verification of the certificate of https requests",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.JSONPathAssertion"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_JSONPathAssertion(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "JSONPathAssertion asserts on the value found at a JSONPath of a JSON response body",
+				Properties: map[string]spec.Schema{
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path is a kubectl style JSONPath template, for example {.status}",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"value": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Value is the expected result of Path, which only has to exist when Value is empty",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"path"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_LatencyThresholds(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "LatencyThresholds degrade or fail a test whose probes passed too slowly. The round trip time of the probe through the Service or Ingress and of the probe of every node are measured, and their percentile is compared to the thresholds",
+				Properties: map[string]spec.Schema{
+					"percentile": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Percentile of the probe latencies compared to the thresholds, between 1 and 100, defaults to 95",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"warn": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Warn marks the test Degraded when the percentile latency exceeds it",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"fail": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Fail marks the test Failed when the percentile latency exceeds it",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_LoadBalancingTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "LoadBalancingTest tunes the loadbalancing test, which sends requests through the Service and Ingress of the agents and counts the requests every agent answered",
+				Properties: map[string]spec.Schema{
+					"requests": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Requests sent through the ClusterIP, and through the Ingress, defaults to 10 per endpoint and at least 100",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_MTUTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "MTUTest tunes the mtu test, in which the agent on a node searches the largest UDP packet, sent with the don't fragment bit set, and TCP payload echoed by the agent on another node",
+				Properties: map[string]spec.Schema{
+					"expectedmtu": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExpectedMTU fails the test when the path MTU between a pair of nodes is below it, defaults to the MTU of the interface of the sending pod",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Mode is sampled or fullmesh, like the mode of the bandwidth test. Defaults to sampled",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout waiting for every packet to be echoed, defaults to 1s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_NetworkPolicyTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "NetworkPolicyTest tunes the networkpolicy test, in which agents allowed by a NetworkPolicy must reach the agent servers, and agents denied by it must not",
+				Properties: map[string]spec.Schema{
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout of every connection, a denied connection still pending after it counts as blocked. Defaults to 2s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_NodeResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "NodeResult is the outcome of a test on a single node",
+				Properties: map[string]spec.Schema{
+					"nodename": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"podname": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"latency": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Latency is the round trip time of the probe against the pod on this node",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"startuplatency": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StartupLatency is the time between the DaemonSet creation and the pod becoming ready",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"target": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Target is the node the probe of this node was sent to, for tests between pairs of nodes",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metrics": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Metrics are the measurements specific to the test, for example the throughput of the bandwidth test",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"nodename", "status"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_ResponseMatch(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ResponseMatch is a response a probe expects",
+				Properties: map[string]spec.Schema{
+					"match": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Match is exact, prefix or regex, defaults to exact",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"value": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+				Required: []string{"value"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_RetryPolicy(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RetryPolicy bounds the retries of a test. Errors and failed probes count as failed attempts and are retried after an exponential backoff, the test is marked Failed and alerted on once the policy is exhausted",
+				Properties: map[string]spec.Schema{
+					"maxattempts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxAttempts is the number of failed attempts before giving up, defaults to 5",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"initialbackoff": {
+						SchemaProps: spec.SchemaProps{
+							Description: "InitialBackoff is the wait after the first failed attempt, doubled after every other one, defaults to 5s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"maxbackoff": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxBackoff caps the wait between two attempts, defaults to 1m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"deadline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Deadline is how long the test is retried for in total, defaults to 30m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_RunHistory(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RunHistory controls how many CoastieRuns are kept per test, whichever limit is hit first wins",
+				Properties: map[string]spec.Schema{
+					"limit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Limit is the number of CoastieRuns kept per test, defaults to 100",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"maxage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxAge is how long CoastieRuns are kept, defaults to 720h",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_SLO(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SLO is the service level objective every test of a Coastie is measured against",
+				Properties: map[string]spec.Schema{
+					"objective": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Objective is the availability target in percent, for example \"99.9\"",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"windows": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Windows are the rolling windows availability is computed over, defaults to 1h, 24h and 720h",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+									},
+								},
+							},
+						},
+					},
+					"burnratethreshold": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BurnRateThreshold alerts when the error budget burn rate over any window exceeds it, for example \"14.4\". Leave empty to disable alerting",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"objective"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_TcpUdpProbe(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TcpUdpProbe customizes the probes of the tcp and udp tests, so they can test other servers and protocols than the bundled echo servers",
+				Properties: map[string]spec.Schema{
+					"host": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Host is probed instead of the bundled echo servers when set, no DaemonSet or Service is created",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"port": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Port of Host, defaults to the port of the bundled echo server",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"payload": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Payload sent once connected, defaults to the question of the bundled echo server",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"encoding": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Encoding of Payload and Expect value, text or base64, defaults to text",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"expect": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Expect is the response the probe expects. Defaults to the answer of the bundled echo server when Payload is not set, and to any response otherwise",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ResponseMatch"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ResponseMatch"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_TestSettings(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TestSettings tune a single test",
+				Properties: map[string]spec.Schema{
+					"retry": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Retry bounds how long the test, and its cleanup, is retried",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RetryPolicy"),
+						},
+					},
+					"timeouts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeouts of the rollout and probes of the test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestTimeouts"),
+						},
+					},
+					"tcpudp": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TcpUdp customizes what the tcp and udp tests send and expect back",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TcpUdpProbe"),
+						},
+					},
+					"http": {
+						SchemaProps: spec.SchemaProps{
+							Description: "HTTP customizes the request the http test sends through the Ingress, and the response it expects",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.HTTPProbe"),
+						},
+					},
+					"latency": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Latency thresholds degrade or fail the test on the round trip time of its probes",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LatencyThresholds"),
+						},
+					},
+					"bandwidth": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Bandwidth tunes the transfers of the bandwidth test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.BandwidthTest"),
+						},
+					},
+					"mtu": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MTU tunes the packet sizes of the mtu test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.MTUTest"),
+						},
+					},
+					"burst": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Burst measures the packet loss, reordering and jitter of the udp test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.UDPBurst"),
+						},
+					},
+					"ipfamilies": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IPFamilies the tcp, udp and http tests run over, IPv4, IPv6 or both. The Service of the test is created with these families, and every family is probed separately. Defaults to the family of the cluster",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"loadbalancing": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LoadBalancing tunes the requests of the loadbalancing test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LoadBalancingTest"),
+						},
+					},
+					"expose": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Expose has the tcp and udp tests probe their servers through a NodePort or LoadBalancer Service too",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ExposeTest"),
+						},
+					},
+					"endpoints": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Endpoints tunes the thresholds of the endpoints test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EndpointsTest"),
+						},
+					},
+					"networkpolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NetworkPolicy tunes the connections of the networkpolicy test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NetworkPolicyTest"),
+						},
+					},
+					"egress": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Egress lists the external targets of the egress test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTest"),
+						},
+					},
+					"grpc": {
+						SchemaProps: spec.SchemaProps{
+							Description: "GRPC tunes the health checks of the grpc test",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.GRPCTest"),
+						},
+					},
+					"websocket": {
+						SchemaProps: spec.SchemaProps{
+							Description: "WebSocket tunes how long the websocket test holds its connections",
+							Ref:         ref("github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.WebSocketTest"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.BandwidthTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EgressTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.EndpointsTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.ExposeTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.GRPCTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.HTTPProbe", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LatencyThresholds", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.LoadBalancingTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.MTUTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.NetworkPolicyTest", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.RetryPolicy", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TcpUdpProbe", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.TestTimeouts", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.UDPBurst", "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1.WebSocketTest"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_TestTimeouts(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TestTimeouts tune how long a test waits on its DaemonSet and its probes",
+				Properties: map[string]spec.Schema{
+					"rolloutdeadline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RolloutDeadline is how long the DaemonSet has to become ready, defaults to the rollouttimeout of the operator",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"probeattempts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ProbeAttempts is the number of probes through the Service or Ingress before the test fails, defaults to 5",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"probeinterval": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ProbeInterval is the wait between two probe attempts, defaults to 2s for tcp and udp, and 6s for http",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"dialtimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DialTimeout is how long a probe waits to connect, defaults to 10s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"readtimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ReadTimeout is how long a probe waits for the response once connected, defaults to 2s for tcp and udp, and 10s for http",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_UDPBurst(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "UDPBurst has the udp test send a burst of sequenced datagrams to the pod on every node, which runs the agent alongside the udp server to echo them",
+				Properties: map[string]spec.Schema{
+					"count": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Count of datagrams in the burst, defaults to 100",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"interval": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Interval between two datagrams, defaults to 10ms",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"size": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Size of every datagram in bytes, at least 16, defaults to 64",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"maxlosspercent": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxLossPercent fails the test when a larger share of the burst to a node is lost, for example \"0.5\". Defaults to \"1\"",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_k8s_v1alpha1_WebSocketTest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "WebSocketTest tunes the websocket test, which holds a WebSocket to the agents open through their Service and Ingress, and reports connections dropped before the end",
+				Properties: map[string]spec.Schema{
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Duration every connection is held for, defaults to 1m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"pinginterval": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PingInterval between two messages echoed by the agent, defaults to 10s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
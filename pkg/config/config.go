@@ -0,0 +1,314 @@
+// Package config holds the runtime settings of the operator, read from an optional configuration file
+// and overridden by environment variables and command line flags.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Prefix of the environment variables overriding the configuration, COASTIE_METRICS_ADDRESS overrides --metrics-address
+const envPrefix = "COASTIE_"
+
+// Watch namespaces keep being read from the environment variable set by operator-sdk deployments
+const watchNamespaceEnv = "WATCH_NAMESPACE"
+
+// Config is the configuration of the operator
+type Config struct {
+	// MetricsAddress is the host:port the Prometheus metrics are served on
+	MetricsAddress string `json:"metricsaddress,omitempty"`
+	// HealthProbeAddress is the host:port the liveness and readiness endpoints are served on, empty disables them
+	HealthProbeAddress string `json:"healthprobeaddress,omitempty"`
+	// StatusAPIAddress is the host:port the status API and dashboard are served on, empty disables them
+	StatusAPIAddress string `json:"statusapiaddress,omitempty"`
+	// WatchNamespaces are the namespaces Coasties are watched in, every namespace when empty
+	WatchNamespaces []string `json:"watchnamespaces,omitempty"`
+	// Interval is the time waited between two runs of the tests of a Coastie
+	Interval metav1.Duration `json:"interval,omitempty"`
+	// RolloutTimeout is how long the DaemonSet of a test has to become ready before the test fails
+	RolloutTimeout metav1.Duration `json:"rollouttimeout,omitempty"`
+	// WatchdogWindow is how long a reconcile in flight can make no progress before the operator is reported as
+	// unhealthy, 0 disables it
+	WatchdogWindow metav1.Duration `json:"watchdogwindow,omitempty"`
+	// Notifier is used by Coasties which do not set their own slack details
+	Notifier Notifier `json:"notifier,omitempty"`
+	// Heartbeat periodically reports that the operator is alive
+	Heartbeat Heartbeat `json:"heartbeat,omitempty"`
+	// MaxConcurrentReconciles is the number of Coasties tested at the same time
+	MaxConcurrentReconciles int `json:"maxconcurrentreconciles,omitempty"`
+	// LogLevel is one of debug, info, error or an integer greater than 0, the zap default when empty
+	LogLevel string `json:"loglevel,omitempty"`
+	// AgentImage is the image run by the DaemonSets of the agent based tests, the operator image started as an agent
+	AgentImage string `json:"agentimage,omitempty"`
+}
+
+// Notifier holds the slack details alerts are sent to
+type Notifier struct {
+	SlackToken     string `json:"slacktoken,omitempty"`
+	SlackChannelID string `json:"slackchannelid,omitempty"`
+}
+
+// Heartbeat is sent to WebhookURL, or to the Notifier when WebhookURL is empty
+type Heartbeat struct {
+	// Interval between two heartbeats, 0 disables them
+	Interval   metav1.Duration `json:"interval,omitempty"`
+	WebhookURL string          `json:"webhookurl,omitempty"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *Config {
+	return &Config{
+		MetricsAddress:          "0.0.0.0:8383",
+		HealthProbeAddress:      "0.0.0.0:8585",
+		StatusAPIAddress:        "0.0.0.0:8484",
+		Interval:                metav1.Duration{Duration: 300 * time.Second},
+		RolloutTimeout:          metav1.Duration{Duration: 5 * time.Minute},
+		WatchdogWindow:          metav1.Duration{Duration: 2 * time.Hour},
+		MaxConcurrentReconciles: 1,
+		AgentImage:              "hub.soh.re/soh.re/coastie-operator",
+	}
+}
+
+// AddFlags registers the configuration flags on fs
+func AddFlags(fs *pflag.FlagSet) {
+	d := Default()
+	fs.String("config", "", "Path of the operator configuration file")
+	fs.String("metrics-address", d.MetricsAddress, "Address the metrics are served on")
+	fs.String("health-probe-address", d.HealthProbeAddress, "Address the liveness and readiness endpoints are served on, empty to disable")
+	fs.String("status-api-address", d.StatusAPIAddress, "Address the status API and dashboard are served on, empty to disable")
+	fs.String("watch-namespaces", "", "Comma separated namespaces to watch Coasties in, every namespace when empty")
+	fs.Duration("interval", d.Interval.Duration, "Time waited between two runs of the tests of a Coastie")
+	fs.Duration("rollout-timeout", d.RolloutTimeout.Duration, "How long the DaemonSet of a test has to become ready")
+	fs.Duration("watchdog-window", d.WatchdogWindow.Duration, "How long a reconcile in flight can make no progress before the operator is reported as unhealthy, 0 to disable")
+	fs.String("slack-token", "", "Slack token used by Coasties which do not set their own")
+	fs.String("slack-channel-id", "", "Slack channel used by Coasties which do not set their own")
+	fs.Duration("heartbeat-interval", 0, "Interval between two heartbeats, 0 to disable them")
+	fs.String("heartbeat-webhook-url", "", "Webhook heartbeats are posted to, the default slack notifier when empty")
+	fs.Int("max-concurrent-reconciles", d.MaxConcurrentReconciles, "Number of Coasties tested at the same time")
+	fs.String("log-level", "", "Log level, one of debug, info, error or an integer greater than 0")
+	fs.String("agent-image", d.AgentImage, "Image run by the DaemonSets of the agent based tests")
+}
+
+// Load reads the configuration file given by --config or COASTIE_CONFIG, overrides it with the COASTIE_
+// environment variables then with the flags set on the command line, and validates the result
+func Load(fs *pflag.FlagSet) (c *Config, err error) {
+	c = Default()
+	if path, ok := lookup(fs, "config"); ok && path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration file: %s", err)
+		}
+		err = yaml.UnmarshalStrict(data, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s: %s", path, err)
+		}
+	}
+
+	var errs []string
+	override := func(name string, set func(value string) error) {
+		value, ok := lookup(fs, name)
+		if !ok {
+			return
+		}
+		if err := set(value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s %q: %s", name, value, err))
+		}
+	}
+	str := func(v *string) func(string) error {
+		return func(value string) error {
+			*v = value
+			return nil
+		}
+	}
+	duration := func(v *metav1.Duration) func(string) error {
+		return func(value string) (err error) {
+			v.Duration, err = time.ParseDuration(value)
+			return err
+		}
+	}
+	override("metrics-address", str(&c.MetricsAddress))
+	override("health-probe-address", str(&c.HealthProbeAddress))
+	override("status-api-address", str(&c.StatusAPIAddress))
+	override("watch-namespaces", func(value string) error {
+		c.WatchNamespaces = splitList(value)
+		return nil
+	})
+	override("interval", duration(&c.Interval))
+	override("rollout-timeout", duration(&c.RolloutTimeout))
+	override("watchdog-window", duration(&c.WatchdogWindow))
+	override("slack-token", str(&c.Notifier.SlackToken))
+	override("slack-channel-id", str(&c.Notifier.SlackChannelID))
+	override("heartbeat-interval", duration(&c.Heartbeat.Interval))
+	override("heartbeat-webhook-url", str(&c.Heartbeat.WebhookURL))
+	override("max-concurrent-reconciles", func(value string) (err error) {
+		c.MaxConcurrentReconciles, err = strconv.Atoi(value)
+		return err
+	})
+	override("log-level", str(&c.LogLevel))
+	override("agent-image", str(&c.AgentImage))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate returns an error listing every invalid setting
+func (c *Config) Validate() error {
+	var errs []string
+	if c.MetricsAddress == "" {
+		errs = append(errs, "metricsaddress must be set")
+	} else if err := validateAddress(c.MetricsAddress); err != nil {
+		errs = append(errs, fmt.Sprintf("metricsaddress %q: %s", c.MetricsAddress, err))
+	}
+	if c.HealthProbeAddress != "" {
+		if err := validateAddress(c.HealthProbeAddress); err != nil {
+			errs = append(errs, fmt.Sprintf("healthprobeaddress %q: %s", c.HealthProbeAddress, err))
+		}
+	}
+	if c.StatusAPIAddress != "" {
+		if err := validateAddress(c.StatusAPIAddress); err != nil {
+			errs = append(errs, fmt.Sprintf("statusapiaddress %q: %s", c.StatusAPIAddress, err))
+		}
+	}
+	watched := map[string]bool{}
+	for _, v := range c.WatchNamespaces {
+		if watched[v] {
+			errs = append(errs, fmt.Sprintf("watchnamespaces %q: listed more than once", v))
+		}
+		watched[v] = true
+		if msgs := validation.IsDNS1123Label(v); len(msgs) > 0 {
+			errs = append(errs, fmt.Sprintf("watchnamespaces %q: %s", v, strings.Join(msgs, ", ")))
+		}
+	}
+	if c.Interval.Duration <= 0 {
+		errs = append(errs, fmt.Sprintf("interval %s: must be greater than 0", c.Interval.Duration))
+	}
+	if c.RolloutTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Sprintf("rollouttimeout %s: must be greater than 0", c.RolloutTimeout.Duration))
+	}
+	if c.WatchdogWindow.Duration < 0 {
+		errs = append(errs, fmt.Sprintf("watchdogwindow %s: must not be negative", c.WatchdogWindow.Duration))
+	} else if c.WatchdogWindow.Duration > 0 && c.WatchdogWindow.Duration <= c.Interval.Duration {
+		errs = append(errs, fmt.Sprintf("watchdogwindow %s: must be greater than the interval, reconciles wait for the interval", c.WatchdogWindow.Duration))
+	} else if c.WatchdogWindow.Duration > 0 && c.WatchdogWindow.Duration <= 2*c.RolloutTimeout.Duration {
+		errs = append(errs, fmt.Sprintf("watchdogwindow %s: must be greater than twice the rollouttimeout, an attempt can wait for a DaemonSet to be deleted then rolled out", c.WatchdogWindow.Duration))
+	}
+	if (c.Notifier.SlackToken == "") != (c.Notifier.SlackChannelID == "") {
+		errs = append(errs, "notifier: slacktoken and slackchannelid must be set together")
+	}
+	if c.Heartbeat.Interval.Duration < 0 {
+		errs = append(errs, fmt.Sprintf("heartbeat.interval %s: must not be negative", c.Heartbeat.Interval.Duration))
+	} else if c.Heartbeat.Interval.Duration > 0 && c.Heartbeat.WebhookURL == "" && c.Notifier.SlackToken == "" {
+		errs = append(errs, "heartbeat: webhookurl or the notifier must be set to send heartbeats")
+	}
+	if c.Heartbeat.WebhookURL != "" {
+		if u, err := url.Parse(c.Heartbeat.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("heartbeat.webhookurl %q: must be an http or https URL", c.Heartbeat.WebhookURL))
+		}
+	}
+	if c.MaxConcurrentReconciles < 1 {
+		errs = append(errs, fmt.Sprintf("maxconcurrentreconciles %d: must be at least 1", c.MaxConcurrentReconciles))
+	}
+	if c.LogLevel != "" {
+		if err := validateLogLevel(c.LogLevel); err != nil {
+			errs = append(errs, fmt.Sprintf("loglevel %q: %s", c.LogLevel, err))
+		}
+	}
+	if c.AgentImage == "" {
+		errs = append(errs, "agentimage must be set")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Namespace returns the namespace the manager cache is restricted to, only set when a single namespace is watched
+func (c *Config) Namespace() string {
+	if len(c.WatchNamespaces) == 1 {
+		return c.WatchNamespaces[0]
+	}
+	return ""
+}
+
+// Watches returns true when Coasties in namespace are watched
+func (c *Config) Watches(namespace string) bool {
+	if len(c.WatchNamespaces) == 0 {
+		return true
+	}
+	for _, v := range c.WatchNamespaces {
+		if v == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsPort returns the port of MetricsAddress
+func (c *Config) MetricsPort() int32 {
+	_, port, _ := net.SplitHostPort(c.MetricsAddress)
+	p, _ := strconv.Atoi(port)
+	return int32(p)
+}
+
+// lookup returns the value of a setting from the command line, or from its environment variable when not empty
+func lookup(fs *pflag.FlagSet, name string) (value string, ok bool) {
+	if f := fs.Lookup(name); f != nil && f.Changed {
+		return f.Value.String(), true
+	}
+	env := envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+	if name == "watch-namespaces" {
+		env = watchNamespaceEnv
+	}
+	value = os.Getenv(env)
+	return value, value != ""
+}
+
+func splitList(value string) (list []string) {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func validateAddress(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// validateLogLevel accepts the same levels as the --zap-level flag
+func validateLogLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "debug", "info", "error":
+		return nil
+	}
+	l, err := strconv.Atoi(level)
+	if err != nil || l <= 0 {
+		return fmt.Errorf("must be one of debug, info, error or an integer greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{
+			name:   "default",
+			modify: func(c *Config) {},
+		},
+		{
+			name:   "missing metrics address",
+			modify: func(c *Config) { c.MetricsAddress = "" },
+			err:    "metricsaddress must be set",
+		},
+		{
+			name:   "port out of range",
+			modify: func(c *Config) { c.HealthProbeAddress = "0.0.0.0:70000" },
+			err:    `healthprobeaddress "0.0.0.0:70000": port must be between 1 and 65535`,
+		},
+		{
+			name:   "disabled health probes",
+			modify: func(c *Config) { c.HealthProbeAddress = "" },
+		},
+		{
+			name:   "invalid namespace",
+			modify: func(c *Config) { c.WatchNamespaces = []string{"Team_A"} },
+			err:    `watchnamespaces "Team_A"`,
+		},
+		{
+			name:   "namespace listed twice",
+			modify: func(c *Config) { c.WatchNamespaces = []string{"team-a", "team-b", "team-a"} },
+			err:    `watchnamespaces "team-a": listed more than once`,
+		},
+		{
+			name:   "zero interval",
+			modify: func(c *Config) { c.Interval.Duration = 0 },
+			err:    "interval 0s: must be greater than 0",
+		},
+		{
+			name:   "watchdog window within the interval",
+			modify: func(c *Config) { c.WatchdogWindow.Duration = c.Interval.Duration },
+			err:    "watchdogwindow 5m0s: must be greater than the interval",
+		},
+		{
+			name: "watchdog window within two rollouts",
+			modify: func(c *Config) {
+				c.RolloutTimeout.Duration = time.Hour
+				c.WatchdogWindow.Duration = 2 * time.Hour
+			},
+			err: "watchdogwindow 2h0m0s: must be greater than twice the rollouttimeout",
+		},
+		{
+			name:   "disabled watchdog",
+			modify: func(c *Config) { c.WatchdogWindow.Duration = 0 },
+		},
+		{
+			name:   "slack token without channel",
+			modify: func(c *Config) { c.Notifier.SlackToken = "xoxb" },
+			err:    "notifier: slacktoken and slackchannelid must be set together",
+		},
+		{
+			name:   "heartbeat without destination",
+			modify: func(c *Config) { c.Heartbeat.Interval.Duration = time.Minute },
+			err:    "heartbeat: webhookurl or the notifier must be set to send heartbeats",
+		},
+		{
+			name: "heartbeat to a webhook",
+			modify: func(c *Config) {
+				c.Heartbeat.Interval.Duration = time.Minute
+				c.Heartbeat.WebhookURL = "https://example.com/heartbeat"
+			},
+		},
+		{
+			name:   "webhook without scheme",
+			modify: func(c *Config) { c.Heartbeat.WebhookURL = "example.com/heartbeat" },
+			err:    `heartbeat.webhookurl "example.com/heartbeat": must be an http or https URL`,
+		},
+		{
+			name:   "no concurrent reconciles",
+			modify: func(c *Config) { c.MaxConcurrentReconciles = 0 },
+			err:    "maxconcurrentreconciles 0: must be at least 1",
+		},
+		{
+			name:   "numeric log level",
+			modify: func(c *Config) { c.LogLevel = "3" },
+		},
+		{
+			name:   "unknown log level",
+			modify: func(c *Config) { c.LogLevel = "warn" },
+			err:    `loglevel "warn"`,
+		},
+		{
+			name:   "missing agent image",
+			modify: func(c *Config) { c.AgentImage = "" },
+			err:    "agentimage must be set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+			err := c.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(file, []byte("interval: 10m\nloglevel: debug\nwatchnamespaces:\n- from-file\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		args       []string
+		interval   time.Duration
+		logLevel   string
+		namespaces []string
+		err        string
+	}{
+		{
+			name:       "file",
+			args:       []string{"--config", file},
+			interval:   10 * time.Minute,
+			logLevel:   "debug",
+			namespaces: []string{"from-file"},
+		},
+		{
+			name:       "config file from the environment",
+			env:        map[string]string{"COASTIE_CONFIG": file},
+			interval:   10 * time.Minute,
+			logLevel:   "debug",
+			namespaces: []string{"from-file"},
+		},
+		{
+			name:       "environment overrides the file",
+			env:        map[string]string{"COASTIE_INTERVAL": "15m", "WATCH_NAMESPACE": "team-a, team-b"},
+			args:       []string{"--config", file},
+			interval:   15 * time.Minute,
+			logLevel:   "debug",
+			namespaces: []string{"team-a", "team-b"},
+		},
+		{
+			name:       "flags override the environment",
+			env:        map[string]string{"COASTIE_INTERVAL": "15m", "COASTIE_LOG_LEVEL": "error"},
+			args:       []string{"--config", file, "--interval", "20m", "--watch-namespaces", "team-c"},
+			interval:   20 * time.Minute,
+			logLevel:   "error",
+			namespaces: []string{"team-c"},
+		},
+		{
+			name:       "empty environment variables are ignored",
+			env:        map[string]string{"COASTIE_INTERVAL": "", "WATCH_NAMESPACE": ""},
+			interval:   5 * time.Minute,
+			namespaces: nil,
+		},
+		{
+			name: "unparsable duration",
+			env:  map[string]string{"COASTIE_INTERVAL": "soon"},
+			err:  `interval "soon"`,
+		},
+		{
+			name: "invalid result",
+			args: []string{"--max-concurrent-reconciles", "0"},
+			err:  "maxconcurrentreconciles 0: must be at least 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"COASTIE_CONFIG", "COASTIE_INTERVAL", "COASTIE_LOG_LEVEL", "WATCH_NAMESPACE"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			AddFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := Load(fs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.Interval.Duration != tt.interval {
+				t.Errorf("interval: expected %s, got %s", tt.interval, c.Interval.Duration)
+			}
+			if c.LogLevel != tt.logLevel {
+				t.Errorf("loglevel: expected %q, got %q", tt.logLevel, c.LogLevel)
+			}
+			if !reflect.DeepEqual(c.WatchNamespaces, tt.namespaces) {
+				t.Errorf("watchnamespaces: expected %v, got %v", tt.namespaces, c.WatchNamespaces)
+			}
+		})
+	}
+}
+
+func TestWatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		namespace  string
+		cache      string
+		watched    bool
+	}{
+		{name: "every namespace", namespace: "team-a", watched: true},
+		{name: "single namespace", namespaces: []string{"team-a"}, namespace: "team-a", cache: "team-a", watched: true},
+		{name: "other namespace", namespaces: []string{"team-a"}, namespace: "team-b", cache: "team-a"},
+		{name: "several namespaces", namespaces: []string{"team-a", "team-b"}, namespace: "team-b", watched: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.WatchNamespaces = tt.namespaces
+			if got := c.Watches(tt.namespace); got != tt.watched {
+				t.Errorf("Watches(%q): expected %t, got %t", tt.namespace, tt.watched, got)
+			}
+			if got := c.Namespace(); got != tt.cache {
+				t.Errorf("Namespace: expected %q, got %q", tt.cache, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/jmainguy/coastie-operator/pkg/controller/clustercoastie"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, clustercoastie.Add)
+}
@@ -0,0 +1,253 @@
+package clustercoastie
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/config"
+	"github.com/jmainguy/coastie-operator/pkg/health"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_clustercoastie")
+
+// Add creates a new ClusterCoastie Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started. ClusterCoasties are skipped when the operator only watches some
+// namespaces, the cache could not see the cluster scoped objects nor the Coasties of the test namespaces.
+func Add(mgr manager.Manager, cfg *config.Config) error {
+	if len(cfg.WatchNamespaces) > 0 {
+		log.Info("Not watching ClusterCoasties, watchnamespaces is set", "WatchNamespaces", cfg.WatchNamespaces)
+		return nil
+	}
+	return add(mgr, newReconciler(mgr), cfg)
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileClusterCoastie{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler, cfg *config.Config) error {
+	// Create a new controller
+	c, err := controller.New("clustercoastie-controller", mgr, controller.Options{
+		Reconciler:              health.Track("clustercoastie-controller", r),
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource ClusterCoastie
+	err = c.Watch(&source.Kind{Type: &k8sv1alpha1.ClusterCoastie{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the Coastie running the tests, so its status is mirrored back
+	err = c.Watch(&source.Kind{Type: &k8sv1alpha1.Coastie{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &k8sv1alpha1.ClusterCoastie{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the test Namespace and requeue the owner ClusterCoastie
+	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &k8sv1alpha1.ClusterCoastie{},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileClusterCoastie implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileClusterCoastie{}
+
+// ReconcileClusterCoastie reconciles a ClusterCoastie object
+type ReconcileClusterCoastie struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile makes sure the test Namespace, its ResourceQuota and the Coastie running the tests exist
+// for a ClusterCoastie, and mirrors the status of that Coastie back onto the ClusterCoastie.
+// The tests themselves are run by the Coastie controller.
+func (r *ReconcileClusterCoastie) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Name", request.Name)
+	reqLogger.Info("Reconciling ClusterCoastie")
+
+	// Fetch the ClusterCoastie instance
+	instance := &k8sv1alpha1.ClusterCoastie{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	namespace := testNamespace(instance)
+	err = r.ensureNamespace(instance, namespace, reqLogger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	err = r.ensureQuota(instance, namespace, reqLogger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	coastie, err := r.ensureCoastie(instance, namespace, reqLogger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Mirror the status of the Coastie running the tests
+	status := k8sv1alpha1.ClusterCoastieStatus{
+		TestNamespace: namespace,
+		CoastieStatus: *coastie.Status.DeepCopy(),
+	}
+	if !reflect.DeepEqual(instance.Status, status) {
+		instance.Status = status
+		err = r.client.Status().Update(context.TODO(), instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to update ClusterCoastie status")
+			return reconcile.Result{}, err
+		}
+	}
+
+	reqLogger.Info("Reconciliation of ClusterCoastie complete", "Namespace", namespace)
+	return reconcile.Result{}, nil
+}
+
+// testNamespace returns the name of the namespace the ClusterCoastie runs its tests in
+func testNamespace(instance *k8sv1alpha1.ClusterCoastie) string {
+	if instance.Spec.TestNamespace != "" {
+		return instance.Spec.TestNamespace
+	}
+	return fmt.Sprintf("coastie-%s", instance.Name)
+}
+
+func (r *ReconcileClusterCoastie) ensureNamespace(instance *k8sv1alpha1.ClusterCoastie, name string, reqLogger logr.Logger) (err error) {
+	namespace := testNamespaceObject(instance, name)
+	// Set ClusterCoastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, namespace, r.scheme); err != nil {
+		return err
+	}
+
+	found := &corev1.Namespace{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Namespace", "Namespace.Name", name)
+		return r.client.Create(context.TODO(), namespace)
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(found, instance) {
+		return fmt.Errorf("namespace %s already exists and is not owned by ClusterCoastie %s", name, instance.Name)
+	}
+
+	// Put the node-selector annotations back if they were changed
+	changed := false
+	if found.Annotations == nil {
+		found.Annotations = make(map[string]string)
+	}
+	for k, v := range namespace.Annotations {
+		if current, ok := found.Annotations[k]; !ok || current != v {
+			found.Annotations[k] = v
+			changed = true
+		}
+	}
+	if changed {
+		reqLogger.Info("Updating Namespace annotations", "Namespace.Name", name)
+		return r.client.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+func (r *ReconcileClusterCoastie) ensureQuota(instance *k8sv1alpha1.ClusterCoastie, namespace string, reqLogger logr.Logger) (err error) {
+	nodeList := &corev1.NodeList{}
+	err = r.client.List(context.TODO(), &client.ListOptions{}, nodeList)
+	if err != nil {
+		return err
+	}
+	quota := testNamespaceQuota(instance, namespace, len(nodeList.Items))
+	// Set ClusterCoastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, quota, r.scheme); err != nil {
+		return err
+	}
+
+	found := &corev1.ResourceQuota{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: quota.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new ResourceQuota", "ResourceQuota.Namespace", namespace, "ResourceQuota.Name", quota.Name)
+		return r.client.Create(context.TODO(), quota)
+	} else if err != nil {
+		return err
+	}
+
+	// Resize the quota when nodes or tests were added or removed
+	if !resourceListEqual(found.Spec.Hard, quota.Spec.Hard) {
+		reqLogger.Info("Updating ResourceQuota", "ResourceQuota.Namespace", namespace, "ResourceQuota.Name", quota.Name)
+		found.Spec.Hard = quota.Spec.Hard
+		return r.client.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+func (r *ReconcileClusterCoastie) ensureCoastie(instance *k8sv1alpha1.ClusterCoastie, namespace string, reqLogger logr.Logger) (coastie *k8sv1alpha1.Coastie, err error) {
+	coastie = testCoastie(instance, namespace)
+	// Set ClusterCoastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, coastie, r.scheme); err != nil {
+		return nil, err
+	}
+
+	found := &k8sv1alpha1.Coastie{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: coastie.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Coastie", "Coastie.Namespace", namespace, "Coastie.Name", coastie.Name)
+		err = r.client.Create(context.TODO(), coastie)
+		if err != nil {
+			return nil, err
+		}
+		return coastie, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Keep the Coastie spec in sync with the ClusterCoastie
+	if !reflect.DeepEqual(found.Spec, coastie.Spec) {
+		reqLogger.Info("Updating Coastie spec", "Coastie.Namespace", namespace, "Coastie.Name", coastie.Name)
+		found.Spec = coastie.Spec
+		err = r.client.Update(context.TODO(), found)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,105 @@
+package clustercoastie
+
+import (
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Every test container requests and is limited to 0.1 cpu and 100M of memory, one pod per node per test
+var (
+	podCPU    = resource.MustParse("0.1")
+	podMemory = resource.MustParse("100M")
+)
+
+// The agents of the bandwidth test are limited to 2 cpu instead, so the transfers are not throttled
+var bandwidthCPU = resource.MustParse("2")
+
+func testNamespaceObject(cr *k8sv1alpha1.ClusterCoastie, name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				// Openshift project node selector, empty allows every node
+				"openshift.io/node-selector": cr.Spec.NodeSelector,
+				// Kubernetes PodNodeSelector admission plugin
+				"scheduler.alpha.kubernetes.io/node-selector": cr.Spec.NodeSelector,
+			},
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "coastie-operator",
+			},
+		},
+	}
+}
+
+func testNamespaceQuota(cr *k8sv1alpha1.ClusterCoastie, namespace string, nodes int) *corev1.ResourceQuota {
+	hard := cr.Spec.Quota
+	if len(hard) == 0 {
+		pods := int64(nodes * len(cr.Spec.Tests))
+		// The networkpolicy test runs allowed and denied agents alongside its servers
+		if hasTest(cr, "networkpolicy") {
+			pods += int64(2 * nodes)
+		}
+		containers := pods
+		// The udp test runs the agent alongside the udp server when it sends a burst
+		if cr.Spec.TestSettings["udp"].Burst != nil && hasTest(cr, "udp") {
+			containers += int64(nodes)
+		}
+		cpu := resource.NewMilliQuantity(podCPU.MilliValue()*containers, resource.DecimalSI)
+		limitsCPU := cpu.DeepCopy()
+		if hasTest(cr, "bandwidth") {
+			limitsCPU.Add(*resource.NewMilliQuantity((bandwidthCPU.MilliValue()-podCPU.MilliValue())*int64(nodes), resource.DecimalSI))
+		}
+		memory := resource.NewQuantity(podMemory.Value()*containers, resource.DecimalSI)
+		hard = corev1.ResourceList{
+			corev1.ResourcePods:           *resource.NewQuantity(pods, resource.DecimalSI),
+			corev1.ResourceLimitsCPU:      limitsCPU,
+			corev1.ResourceLimitsMemory:   *memory,
+			corev1.ResourceRequestsCPU:    *cpu,
+			corev1.ResourceRequestsMemory: *memory,
+		}
+	}
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coastie",
+			Namespace: namespace,
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}
+}
+
+func testCoastie(cr *k8sv1alpha1.ClusterCoastie, namespace string) *k8sv1alpha1.Coastie {
+	return &k8sv1alpha1.Coastie{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Name,
+			Namespace: namespace,
+		},
+		Spec: *cr.Spec.CoastieSpec.DeepCopy(),
+	}
+}
+
+// hasTest returns true when the ClusterCoastie runs testName
+func hasTest(cr *k8sv1alpha1.ClusterCoastie, testName string) bool {
+	for _, v := range cr.Spec.Tests {
+		if v == testName {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceListEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		other, ok := b[k]
+		if !ok || v.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,334 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	instr "k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// agentProbe runs the probes of an agent based test against the agent pods of every node, calling their control
+// API with c. It returns a SUCCESS or ERROR status along with the round trip times of the probes, nil when the
+// test does not measure any, and the per node results
+type agentProbe func(ctx context.Context, c *agent.Client, pods []corev1.Pod) (status string, latencies []time.Duration, nodes []k8sv1alpha1.NodeResult)
+
+// runAgentTest runs a test whose DaemonSet runs the agent. Objects the test needs besides are created along with
+// the DaemonSet, and the DaemonSets among them are waited on too. Once the agent is ready on every node, probe is
+// run against the agent pods and its status completes the test, checked against the latency thresholds of the
+// tests measuring a round trip time
+func runAgentTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, objects []runtime.Object, probe agentProbe) (err error, retry bool) {
+	retry = false
+	name := fmt.Sprintf("%s-%s", instance.Name, testName)
+	timeouts := testTimeouts(instance, r, testName)
+	if _, err := testLatencyThresholds(instance, testName); err != nil {
+		return err, retry
+	}
+	// Every phase the test goes through gets its own span, the current one is ended on return
+	testCtx := ctx
+	ctx, span := startTestSpan(testCtx, "DaemonSet", instance, testName)
+	defer func() { endSpan(span, err) }()
+	// The agents only answer the operator on their control API
+	token, err := ensureAgentToken(ctx, instance, r, reqLogger)
+	if err != nil {
+		return err, retry
+	}
+	// Define a new DaemonSet object
+	agentDaemonSet := agentServer(instance, name, r.config.AgentImage)
+	if testName == "bandwidth" {
+		// A transfer is bound by the CPU of the agents long before 0.1 cpu, let them burst to measure the link
+		agentDaemonSet.Spec.Template.Spec.Containers[0].Resources.Limits["cpu"] = bandwidthAgentCPU
+	}
+	if egress := testSettings(instance, testName).Egress; testName == "egress" && egress != nil {
+		// The agents only probe the targets of the Coastie
+		container := &agentDaemonSet.Spec.Template.Spec.Containers[0]
+		for _, target := range egress.Targets {
+			container.Args = append(container.Args, agent.EgressTargetArg(target.Type, target.Address))
+		}
+	}
+	// Set Coastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, agentDaemonSet, r.scheme); err != nil {
+		return err, retry
+	}
+
+	// Check if this DaemonSet already exists
+	TestStatus := instance.Status.TestResults[testName]
+	found := &appsv1.DaemonSet{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		for _, object := range objects {
+			err = createIfNotFound(ctx, instance, r, reqLogger, object)
+			if err != nil {
+				return err, retry
+			}
+		}
+		reqLogger.Info("Creating a new DaemonSet", "DaemonSet.Namespace", agentDaemonSet.Namespace, "DaemonSet.Name", name)
+		err = r.client.Create(ctx, agentDaemonSet)
+		if err != nil {
+			return err, retry
+		}
+		// DaemonSet created successfully - return and requeue
+		dsct := time.Now().Format(time.RFC3339)
+		TestStatus.DaemonSetCreationTime = dsct
+		TestStatus.Status = "Running"
+		TestStatus.Latency = nil
+		err = updateCoastieStatus(instance, TestStatus, testName, reqLogger, r)
+		if err != nil {
+			return err, retry
+		}
+		reqLogger.Info("Daemonset Created Successfully", "DaemonSetCreationTime", dsct, "DaemonSet.Namespace", agentDaemonSet.Namespace, "DaemonSet.Name", name)
+		retry = true
+		return nil, retry
+	} else if err != nil {
+		return err, retry
+	}
+
+	daemonSets := []string{name}
+	for _, object := range objects {
+		if ds, ok := object.(*appsv1.DaemonSet); ok {
+			daemonSets = append(daemonSets, ds.Name)
+		}
+	}
+	notReady, err := daemonSetsNotReady(ctx, r, instance.Namespace, daemonSets)
+	if err != nil {
+		return err, retry
+	}
+	if len(notReady) > 0 {
+		span.End()
+		ctx, span = startTestSpan(testCtx, "DaemonSet wait", instance, testName)
+		polls, interval := rolloutPolls(timeouts.rollout)
+		for i := 0; i < polls; i++ {
+			// Wait before checking the DaemonSets again
+			time.Sleep(interval)
+			notReady, err = daemonSetsNotReady(ctx, r, instance.Namespace, daemonSets)
+			if err != nil {
+				return err, retry
+			}
+			if len(notReady) == 0 {
+				reqLogger.Info("DaemonSet is ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
+				retry = true
+				return nil, retry
+			}
+			reqLogger.Info("DaemonSet is not ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", notReady)
+		}
+		// If here, means Daemonset to not become ready within the rollout timeout
+		var nodes []string
+		seen := make(map[string]bool)
+		for _, v := range notReady {
+			for _, node := range getNodesWithoutPods(r, v, instance.Namespace) {
+				if !seen[node] {
+					seen[node] = true
+					nodes = append(nodes, node)
+				}
+			}
+		}
+		status := fmt.Sprintf("ERROR: DaemonSet took longer than %s to become ready, nodes with issues: %s", timeouts.rollout, nodes)
+		return failTest(instance, r, reqLogger, testName, TestStatus, status, missingNodeResults(nodes))
+	}
+
+	// All agents are ready, run the probes of the test against them
+	span.End()
+	ctx, span = startTestSpan(testCtx, "Probe", instance, testName)
+	status, latencies, nodes := probe(ctx, agent.NewClient(token), listTestPods(r, name, found.Namespace))
+	if !strings.Contains(status, "SUCCESS") {
+		return failTest(instance, r, reqLogger, testName, TestStatus, status, nodes)
+	}
+	if latencyTest(testName) {
+		err, retry = passTest(ctx, instance, r, reqLogger, testName, TestStatus, status, latencies, nodes)
+		if err != nil {
+			return err, retry
+		}
+	} else {
+		TestStatus.Status = "Passed"
+		err = completeTest(ctx, instance, r, reqLogger, testName, TestStatus, status, nodes)
+		if err != nil {
+			return err, retry
+		}
+	}
+	reqLogger.Info("Reached end of Test", "TestName", strings.ToUpper(testName))
+	return nil, retry
+}
+
+// daemonSetsNotReady returns the DaemonSets, among names, which are not ready on every node
+func daemonSetsNotReady(ctx context.Context, r *ReconcileCoastie, namespace string, names []string) (notReady []string, err error) {
+	for _, name := range names {
+		found := &appsv1.DaemonSet{}
+		err = r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, found)
+		if err != nil && errors.IsNotFound(err) {
+			// The cache has yet to see a DaemonSet just created
+			notReady = append(notReady, name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if found.Status.DesiredNumberScheduled != found.Status.NumberReady {
+			notReady = append(notReady, name)
+		}
+	}
+	return notReady, nil
+}
+
+// CPU limit of the agents of the bandwidth test, which still request 0.1 cpu
+var bandwidthAgentCPU = resource.MustParse("2")
+
+// agentServer returns the DaemonSet running the agent on every node
+func agentServer(cr *k8sv1alpha1.Coastie, name, image string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						agentContainer(name, image, agentTokenSecret(cr)),
+					},
+				},
+			},
+		},
+	}
+}
+
+// agentContainer returns the container running the agent, with the token of its control API read from the
+// tokenSecret Secret
+func agentContainer(name, image, tokenSecret string) corev1.Container {
+	return corev1.Container{
+		Name:  name,
+		Image: image,
+		Args:  []string{agent.Command},
+		Env: []corev1.EnvVar{
+			{
+				Name: agent.PodNameEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+				},
+			},
+			{
+				Name: agent.NodeNameEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+				},
+			},
+			{
+				Name: agent.TokenEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: tokenSecret},
+						Key:                  agent.TokenKey,
+					},
+				},
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "control",
+				ContainerPort: agent.ControlPort,
+			},
+			{
+				Name:          "sink",
+				ContainerPort: agent.SinkPort,
+			},
+			{
+				Name:          "echo-tcp",
+				ContainerPort: agent.EchoPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+			{
+				Name:          "echo-udp",
+				ContainerPort: agent.EchoPort,
+				Protocol:      corev1.ProtocolUDP,
+			},
+			{
+				Name:          "grpc",
+				ContainerPort: agent.GRPCPort,
+			},
+			{
+				Name:          "public",
+				ContainerPort: agent.PublicPort,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: "/healthz",
+					Port: instr.FromInt(agent.ControlPort),
+				},
+			},
+		},
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				"cpu":    resource.MustParse("0.1"),
+				"memory": resource.MustParse("100M"),
+			},
+			Requests: corev1.ResourceList{
+				"cpu":    resource.MustParse("0.1"),
+				"memory": resource.MustParse("100M"),
+			},
+		},
+	}
+}
+
+// nodePairs returns the pairs of agent pods probed by the tests between nodes. Sampled pairs every pod with the
+// next one of a shuffled ring, so every node sends and receives once. Fullmesh pairs every pod with every other pod
+func nodePairs(pods []corev1.Pod, mode string) (pairs [][2]corev1.Pod) {
+	var ready []corev1.Pod
+	for _, pod := range pods {
+		if pod.Status.PodIP != "" {
+			ready = append(ready, pod)
+		}
+	}
+	if len(ready) < 2 {
+		return nil
+	}
+	if mode == "fullmesh" {
+		for _, from := range ready {
+			for _, to := range ready {
+				if from.Name != to.Name {
+					pairs = append(pairs, [2]corev1.Pod{from, to})
+				}
+			}
+		}
+		return pairs
+	}
+	ring := rand.Perm(len(ready))
+	for i := range ring {
+		pairs = append(pairs, [2]corev1.Pod{ready[ring[i]], ready[ring[(i+1)%len(ring)]]})
+	}
+	return pairs
+}
+
+// deleteAgentTest deletes the agent DaemonSet of a test
+func deleteAgentTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string) (err error) {
+	name := fmt.Sprintf("%s-%s", instance.Name, testName)
+	// Delete DaemonSet
+	agentDaemonSet := agentServer(instance, name, r.config.AgentImage)
+	err = r.client.Delete(ctx, agentDaemonSet)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package coastie
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNodePairs(t *testing.T) {
+	// pods returns n agent pods, the first unready of them without a pod IP
+	pods := func(n, unready int) (pods []corev1.Pod) {
+		for i := 0; i < n; i++ {
+			pod := corev1.Pod{}
+			pod.Name = fmt.Sprintf("agent-%d", i)
+			pod.Spec.NodeName = fmt.Sprintf("node-%d", i)
+			if i >= unready {
+				pod.Status.PodIP = fmt.Sprintf("10.0.0.%d", i)
+			}
+			pods = append(pods, pod)
+		}
+		return pods
+	}
+
+	tests := []struct {
+		name  string
+		pods  []corev1.Pod
+		mode  string
+		pairs int
+	}{
+		{name: "no pods", mode: "sampled"},
+		{name: "single node", pods: pods(1, 0), mode: "sampled"},
+		{name: "single ready pod", pods: pods(3, 2), mode: "fullmesh"},
+		{name: "sampled two nodes", pods: pods(2, 0), mode: "sampled", pairs: 2},
+		{name: "sampled", pods: pods(5, 0), mode: "sampled", pairs: 5},
+		{name: "sampled skips unready pods", pods: pods(5, 2), mode: "sampled", pairs: 3},
+		{name: "fullmesh two nodes", pods: pods(2, 0), mode: "fullmesh", pairs: 2},
+		{name: "fullmesh", pods: pods(5, 0), mode: "fullmesh", pairs: 20},
+		{name: "fullmesh skips unready pods", pods: pods(5, 1), mode: "fullmesh", pairs: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs := nodePairs(tt.pods, tt.mode)
+			if len(pairs) != tt.pairs {
+				t.Fatalf("expected %d pairs, got %d", tt.pairs, len(pairs))
+			}
+			sends := make(map[string]int)
+			receives := make(map[string]int)
+			seen := make(map[[2]string]bool)
+			for _, pair := range pairs {
+				from, to := pair[0], pair[1]
+				if from.Status.PodIP == "" || to.Status.PodIP == "" {
+					t.Errorf("pair %s to %s has a pod without an IP", from.Name, to.Name)
+				}
+				if from.Name == to.Name {
+					t.Errorf("pod %s is paired with itself", from.Name)
+				}
+				if seen[[2]string{from.Name, to.Name}] {
+					t.Errorf("pair %s to %s is probed twice", from.Name, to.Name)
+				}
+				seen[[2]string{from.Name, to.Name}] = true
+				sends[from.Name]++
+				receives[to.Name]++
+			}
+			if tt.mode != "sampled" {
+				return
+			}
+			// Every ready node sends and receives exactly once
+			for _, pod := range tt.pods {
+				if pod.Status.PodIP == "" || len(pairs) == 0 {
+					continue
+				}
+				if sends[pod.Name] != 1 || receives[pod.Name] != 1 {
+					t.Errorf("pod %s sends %d times and receives %d times, expected once each", pod.Name, sends[pod.Name], receives[pod.Name])
+				}
+			}
+		})
+	}
+}
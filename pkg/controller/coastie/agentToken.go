@@ -0,0 +1,87 @@
+package coastie
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// How long a Secret created by a previous attempt can take to show up in the cache
+const (
+	agentTokenCachePolls    = 10
+	agentTokenCacheInterval = 500 * time.Millisecond
+)
+
+// agentTokenSecret returns the name of the Secret holding the token of the control API of the agents of a Coastie
+func agentTokenSecret(cr *k8sv1alpha1.Coastie) string {
+	return fmt.Sprintf("%s-agent-token", cr.Name)
+}
+
+// ensureAgentToken returns the token the agents of the Coastie accept on their control API. It is read from the
+// Secret of the Coastie, created with a random token the first time
+func ensureAgentToken(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (token string, err error) {
+	name := agentTokenSecret(instance)
+	found := &corev1.Secret{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	if err == nil {
+		return secretToken(found)
+	} else if !errors.IsNotFound(err) {
+		return "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := cryptorand.Read(random); err != nil {
+		return "", err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.Namespace,
+		},
+		Data: map[string][]byte{
+			agent.TokenKey: []byte(hex.EncodeToString(random)),
+		},
+	}
+	// Set Coastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
+		return "", err
+	}
+	reqLogger.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", name)
+	err = r.client.Create(ctx, secret)
+	if err == nil {
+		return secretToken(secret)
+	} else if !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	// The cache has yet to see the Secret created by a previous attempt
+	for i := 0; i < agentTokenCachePolls; i++ {
+		time.Sleep(agentTokenCacheInterval)
+		err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+		if err == nil {
+			return secretToken(found)
+		} else if !errors.IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("Secret %s exists but is not in the cache", name)
+}
+
+// secretToken returns the token held by secret
+func secretToken(secret *corev1.Secret) (token string, err error) {
+	token = string(secret.Data[agent.TokenKey])
+	if token == "" {
+		return "", fmt.Errorf("Secret %s has no %s", secret.Name, agent.TokenKey)
+	}
+	return token, nil
+}
@@ -0,0 +1,123 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Windows used when the SLO does not set any
+var defaultSLOWindows = []time.Duration{1 * time.Hour, 24 * time.Hour, 720 * time.Hour}
+
+// Number of buckets the runs of a window are counted in, the oldest bucket may have partly left the window
+const availabilityBuckets = 24
+
+// sloWindows returns the rolling windows availability is computed over
+func sloWindows(slo *k8sv1alpha1.SLO) (windows []time.Duration) {
+	for _, v := range slo.Windows {
+		if v.Duration > 0 {
+			windows = append(windows, v.Duration)
+		}
+	}
+	if len(windows) == 0 {
+		windows = defaultSLOWindows
+	}
+	return windows
+}
+
+// parseObjective parses the SLO objective as a fraction
+func parseObjective(slo *k8sv1alpha1.SLO) (objective float64, err error) {
+	objective, err = strconv.ParseFloat(slo.Objective, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SLO objective %q: %s", slo.Objective, err)
+	}
+	if objective <= 0 || objective >= 100 {
+		return 0, fmt.Errorf("invalid SLO objective %q: must be between 0 and 100 exclusive", slo.Objective)
+	}
+	return objective / 100, nil
+}
+
+// computeAvailability counts the run of a test which just ended, failed or not, and computes the availability and
+// error budget burn rate of the test over every SLO window. previous is the availability last saved in the status,
+// holding the run buckets of every window, and used to only alert when a window starts burning its budget too fast
+func computeAvailability(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, failed bool, previous []k8sv1alpha1.WindowAvailability) (availability []k8sv1alpha1.WindowAvailability, err error) {
+	slo := instance.Spec.SLO
+	objective, err := parseObjective(slo)
+	if err != nil {
+		return nil, err
+	}
+	var threshold float64
+	if slo.BurnRateThreshold != "" {
+		threshold, err = strconv.ParseFloat(slo.BurnRateThreshold, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLO burnratethreshold %q: %s", slo.BurnRateThreshold, err)
+		}
+	}
+	wasAlerting := make(map[time.Duration]bool)
+	buckets := make(map[time.Duration][]k8sv1alpha1.RunBucket)
+	for _, v := range previous {
+		wasAlerting[v.Window.Duration] = v.Alerting
+		buckets[v.Window.Duration] = v.Buckets
+	}
+
+	now := time.Now()
+	for _, window := range sloWindows(slo) {
+		wa := k8sv1alpha1.WindowAvailability{
+			Window:  metav1.Duration{Duration: window},
+			Buckets: countRun(buckets[window], window, now, failed),
+		}
+		for _, v := range wa.Buckets {
+			wa.Runs += v.Runs
+			// Degraded runs passed, only too slowly
+			wa.FailedRuns += v.FailedRuns
+		}
+		if wa.Runs > 0 {
+			ratio := float64(wa.Runs-wa.FailedRuns) / float64(wa.Runs)
+			burnRate := (1 - ratio) / (1 - objective)
+			wa.Availability = strconv.FormatFloat(ratio*100, 'f', 3, 64)
+			wa.BurnRate = strconv.FormatFloat(burnRate, 'f', 2, 64)
+			wa.Alerting = threshold > 0 && burnRate > threshold
+			setAvailabilityMetrics(instance, testName, window, ratio, burnRate)
+
+			if wa.Alerting && !wasAlerting[window] {
+				message := fmt.Sprintf("Coastie Operator: %s error budget burning %.2fx over %s, availability %s%% with an objective of %s%%", strings.ToUpper(testName), burnRate, window, wa.Availability, slo.Objective)
+				err := notify(ctx, instance, r, testName, message)
+				if err != nil {
+					reqLogger.Error(err, "Failed to send slack message")
+				}
+			}
+		}
+		availability = append(availability, wa)
+	}
+	return availability, nil
+}
+
+// countRun adds a run ended at now to the buckets of window, and drops the buckets which left the window. Each
+// bucket covers a slice of availabilityBuckets of the window
+func countRun(buckets []k8sv1alpha1.RunBucket, window time.Duration, now time.Time, failed bool) (counted []k8sv1alpha1.RunBucket) {
+	width := window / availabilityBuckets
+	if width <= 0 {
+		width = window
+	}
+	for _, v := range buckets {
+		if v.Start.Add(width).After(now.Add(-window)) {
+			counted = append(counted, v)
+		}
+	}
+	start := now.Truncate(width)
+	if len(counted) == 0 || !counted[len(counted)-1].Start.Time.Equal(start) {
+		counted = append(counted, k8sv1alpha1.RunBucket{Start: metav1.NewTime(start)})
+	}
+	last := &counted[len(counted)-1]
+	last.Runs++
+	if failed {
+		last.FailedRuns++
+	}
+	return counted
+}
@@ -0,0 +1,66 @@
+package coastie
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCountRun(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	bucket := func(start time.Time, runs, failed int) k8sv1alpha1.RunBucket {
+		return k8sv1alpha1.RunBucket{Start: metav1.NewTime(start), Runs: runs, FailedRuns: failed}
+	}
+
+	tests := []struct {
+		name    string
+		buckets []k8sv1alpha1.RunBucket
+		window  time.Duration
+		failed  bool
+		counted []k8sv1alpha1.RunBucket
+	}{
+		{
+			name:    "first run",
+			window:  24 * time.Hour,
+			counted: []k8sv1alpha1.RunBucket{bucket(now, 1, 0)},
+		},
+		{
+			name:    "same bucket",
+			buckets: []k8sv1alpha1.RunBucket{bucket(now, 2, 1)},
+			window:  24 * time.Hour,
+			failed:  true,
+			counted: []k8sv1alpha1.RunBucket{bucket(now, 3, 2)},
+		},
+		{
+			name:    "next bucket",
+			buckets: []k8sv1alpha1.RunBucket{bucket(now.Add(-time.Hour), 2, 1)},
+			window:  24 * time.Hour,
+			counted: []k8sv1alpha1.RunBucket{bucket(now.Add(-time.Hour), 2, 1), bucket(now, 1, 0)},
+		},
+		{
+			name: "bucket partly in the window",
+			buckets: []k8sv1alpha1.RunBucket{
+				bucket(now.Add(-25*time.Hour), 4, 4),
+				bucket(now.Add(-24*time.Hour), 3, 0),
+			},
+			window:  24 * time.Hour,
+			counted: []k8sv1alpha1.RunBucket{bucket(now.Add(-24*time.Hour), 3, 0), bucket(now, 1, 0)},
+		},
+		{
+			name:    "window shorter than the buckets",
+			window:  10 * time.Nanosecond,
+			counted: []k8sv1alpha1.RunBucket{bucket(now, 1, 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted := countRun(tt.buckets, tt.window, now, tt.failed)
+			if !reflect.DeepEqual(counted, tt.counted) {
+				t.Errorf("expected %v, got %v", tt.counted, counted)
+			}
+		})
+	}
+}
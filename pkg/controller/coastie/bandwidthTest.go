@@ -0,0 +1,129 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Settings of the bandwidth test used when the test does not set them
+const (
+	defaultBandwidthBytes   = 64 * 1024 * 1024
+	defaultBandwidthMode    = "sampled"
+	defaultBandwidthTimeout = 1 * time.Minute
+)
+
+// bandwidthSettings of the bandwidth test, with the defaults applied
+type bandwidthSettings struct {
+	bytes   int64
+	mode    string
+	minMbps int
+	timeout time.Duration
+}
+
+// testBandwidthSettings returns the settings of the bandwidth test
+func testBandwidthSettings(instance *k8sv1alpha1.Coastie) (settings bandwidthSettings, err error) {
+	settings = bandwidthSettings{
+		bytes:   defaultBandwidthBytes,
+		mode:    defaultBandwidthMode,
+		timeout: defaultBandwidthTimeout,
+	}
+	bandwidth := testSettings(instance, "bandwidth").Bandwidth
+	if bandwidth == nil {
+		return settings, nil
+	}
+	if bandwidth.Bytes != nil {
+		settings.bytes = bandwidth.Bytes.Value()
+		if settings.bytes <= 0 || settings.bytes > agent.MaxBandwidthBytes {
+			return settings, fmt.Errorf("invalid bandwidth settings: bytes %s must be greater than 0 and at most %s", bandwidth.Bytes.String(), resource.NewQuantity(agent.MaxBandwidthBytes, resource.BinarySI))
+		}
+	}
+	switch bandwidth.Mode {
+	case "":
+	case "sampled", "fullmesh":
+		settings.mode = bandwidth.Mode
+	default:
+		return settings, fmt.Errorf("invalid bandwidth settings: mode %s must be sampled or fullmesh", bandwidth.Mode)
+	}
+	if bandwidth.MinMbps < 0 {
+		return settings, fmt.Errorf("invalid bandwidth settings: minmbps %d can not be negative", bandwidth.MinMbps)
+	}
+	settings.minMbps = bandwidth.MinMbps
+	durationOrDefault(&settings.timeout, bandwidth.Timeout)
+	if settings.timeout > agent.MaxTimeout {
+		return settings, fmt.Errorf("invalid bandwidth settings: timeout %s must be at most %s", settings.timeout, agent.MaxTimeout)
+	}
+	return settings, nil
+}
+
+func runBandwidthTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	settings, err := testBandwidthSettings(instance)
+	if err != nil {
+		return err, retry
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "bandwidth", nil, func(ctx context.Context, c *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		status, nodes := bandwidthProbe(ctx, c, instance, reqLogger, settings, pods)
+		return status, nil, nodes
+	})
+}
+
+// bandwidthProbe sends data between the agents of the pairs of nodes, one pair at a time so the transfers do
+// not compete with each other
+func bandwidthProbe(ctx context.Context, c *agent.Client, instance *k8sv1alpha1.Coastie, reqLogger logr.Logger, settings bandwidthSettings, pods []corev1.Pod) (status string, nodes []k8sv1alpha1.NodeResult) {
+	pairs := nodePairs(pods, settings.mode)
+	if len(pairs) == 0 {
+		return "SUCCESS: BANDWIDTH needs at least 2 nodes, no transfer was made", nil
+	}
+	var failed []string
+	for _, pair := range pairs {
+		from, to := pair[0], pair[1]
+		node := k8sv1alpha1.NodeResult{
+			NodeName: from.Spec.NodeName,
+			PodName:  from.Name,
+			Target:   to.Spec.NodeName,
+		}
+		_, span := startTestSpan(ctx, "Probe pair", instance, "bandwidth", tracing.NodeKey.String(from.Spec.NodeName), tracing.TargetKey.String(to.Spec.NodeName))
+		result, err := c.Bandwidth(ctx, from.Status.PodIP, to.Status.PodIP, settings.bytes, settings.timeout)
+		pairStatus := "SUCCESS"
+		if err != nil {
+			pairStatus = fmt.Sprintf("ERROR: %s", err)
+		} else {
+			mbps := result.Mbps()
+			node.Latency = &metav1.Duration{Duration: result.Duration}
+			node.Metrics = map[string]string{
+				"bytes": fmt.Sprintf("%d", result.Bytes),
+				"mbps":  fmt.Sprintf("%.1f", mbps),
+			}
+			if mbps < float64(settings.minMbps) {
+				pairStatus = fmt.Sprintf("ERROR: %.1fMbps is below %dMbps", mbps, settings.minMbps)
+			}
+		}
+		endProbeSpan(span, pairStatus)
+		reqLogger.Info("Bandwidth between nodes", "From", from.Spec.NodeName, "To", to.Spec.NodeName, "Throughput", node.Metrics["mbps"], "Status", pairStatus)
+		if pairStatus == "SUCCESS" {
+			node.Status = "Passed"
+		} else {
+			node.Status = "Failed"
+			node.Message = pairStatus
+			failed = append(failed, fmt.Sprintf("%s->%s (%s)", from.Spec.NodeName, to.Spec.NodeName, strings.TrimPrefix(pairStatus, "ERROR: ")))
+		}
+		nodes = append(nodes, node)
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("ERROR: BANDWIDTH Failed between nodes: %s", strings.Join(failed, ", ")), nodes
+	}
+	return "SUCCESS: BANDWIDTH is working", nodes
+}
+
+func deleteBandwidthTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	return deleteAgentTest(ctx, instance, r, reqLogger, "bandwidth")
+}
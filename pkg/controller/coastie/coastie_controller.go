@@ -2,18 +2,25 @@ package coastie
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/config"
+	"github.com/jmainguy/coastie-operator/pkg/health"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/source"
@@ -23,25 +30,34 @@ var log = logf.Log.WithName("controller_coastie")
 
 // Add creates a new Coastie Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+func Add(mgr manager.Manager, cfg *config.Config) error {
+	return add(mgr, newReconciler(mgr, cfg), cfg)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileCoastie{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+func newReconciler(mgr manager.Manager, cfg *config.Config) reconcile.Reconciler {
+	return &ReconcileCoastie{client: mgr.GetClient(), scheme: mgr.GetScheme(), config: cfg}
 }
 
+// Name of the controller, reported by the health watchdog
+const controllerName = "coastie-controller"
+
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, cfg *config.Config) error {
 	// Create a new controller
-	c, err := controller.New("coastie-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              health.Track(controllerName, r),
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
 
+	// Only watch the configured namespaces, the manager cache can only be restricted to a single one
+	watched := namespacePredicate(cfg)
+
 	// Watch for changes to primary resource Coastie
-	err = c.Watch(&source.Kind{Type: &k8sv1alpha1.Coastie{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &k8sv1alpha1.Coastie{}}, &handler.EnqueueRequestForObject{}, watched)
 	if err != nil {
 		return err
 	}
@@ -50,7 +66,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &k8sv1alpha1.Coastie{},
-	})
+	}, watched)
 	if err != nil {
 		return err
 	}
@@ -67,6 +83,7 @@ type ReconcileCoastie struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	config *config.Config
 }
 
 // Reconcile reads that state of the cluster for a Coastie object and makes changes based on the state read
@@ -77,140 +94,166 @@ type ReconcileCoastie struct {
 func (r *ReconcileCoastie) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling Coastie")
+	ctx, span := tracing.Tracer().Start(context.Background(), "Reconcile", trace.WithAttributes(
+		tracing.CoastieNamespaceKey.String(request.Namespace),
+		tracing.CoastieNameKey.String(request.Name),
+	))
 
 	// Fetch the Coastie instance
 	instance := &k8sv1alpha1.Coastie{}
-	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	err := r.client.Get(ctx, request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			span.End()
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
+		endSpan(span, err)
 		return reconcile.Result{}, err
 	}
 
-	runTests(instance, r, reqLogger)
-	cleanUpTests(instance, r, reqLogger)
+	runTests(ctx, instance, r, reqLogger)
+	cleanUpTests(ctx, instance, r, reqLogger)
+	span.End()
 	reqLogger.Info("Reconciliation of Coastie complete")
+	health.Reconciles.Progress(controllerName, request)
 	// RequeueAfter is not working, its requeing instantly on openshift 3.11
-	// For that reason we will just sleep 300 seconds and then try and requeue
-	time.Sleep(300 * time.Second)
+	// For that reason we will just sleep for the configured interval and then try and requeue
+	time.Sleep(r.config.Interval.Duration)
 	return reconcile.Result{
 		RequeueAfter: 1 * time.Second,
 	}, nil
 }
 
-func runTests(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) {
+func runTests(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) {
 	// Check for tests
-	tests := instance.Spec.Tests
-	for _, v := range tests {
-		if v == "tcp" {
-			reqLogger.Info("Begining Test", "TestName", strings.ToUpper("tcp"))
-			retry := true
-			for retry {
-				retry = runTest("tcp", instance, r, reqLogger)
-			}
-		} else if v == "udp" {
-			reqLogger.Info("Begining Test", "TestName", strings.ToUpper("udp"))
-			retry := true
-			for retry {
-				retry = runTest("udp", instance, r, reqLogger)
-			}
-		} else if v == "http" {
-			reqLogger.Info("Begining Test", "TestName", strings.ToUpper("http"))
-			retry := true
-			for retry {
-				retry = runTest("http", instance, r, reqLogger)
-			}
+	for _, testName := range instance.Spec.Tests {
+		if !knownTest(testName) {
+			reqLogger.Info("Skipping unknown Test", "TestName", testName)
+			continue
+		}
+		reqLogger.Info("Begining Test", "TestName", strings.ToUpper(testName))
+		ctx, span := startTestSpan(ctx, "Test", instance, testName)
+		err := retryTest(instance, reqLogger, testName, func() (retry bool, err error) {
+			return runTest(ctx, testName, instance, r, reqLogger)
+		})
+		if err != nil {
+			giveUpTest(ctx, instance, r, reqLogger, testName, err)
 		}
+		endSpan(span, err)
 	}
-
 }
 
-func cleanUpTests(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) {
+func cleanUpTests(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) {
 	// Clean up old deployments
-	tests := instance.Spec.Tests
-	for _, v := range tests {
-		if v == "tcp" {
-			reqLogger.Info("Cleaning Up Test", "TestName", strings.ToUpper("tcp"))
-			retry := true
-			for retry {
-				retry = cleanUpTest("tcp", instance, r, reqLogger)
-			}
-		} else if v == "udp" {
-			reqLogger.Info("Cleaning Up Test", "TestName", strings.ToUpper("udp"))
-			retry := true
-			for retry {
-				retry = cleanUpTest("udp", instance, r, reqLogger)
-			}
-		} else if v == "http" {
-			reqLogger.Info("Cleaning Up Test", "TestName", strings.ToUpper("http"))
-			retry := true
-			for retry {
-				retry = cleanUpTest("http", instance, r, reqLogger)
+	for _, testName := range instance.Spec.Tests {
+		if !knownTest(testName) {
+			continue
+		}
+		reqLogger.Info("Cleaning Up Test", "TestName", strings.ToUpper(testName))
+		ctx, span := startTestSpan(ctx, "Cleanup", instance, testName)
+		err := retryTest(instance, reqLogger, testName, func() (retry bool, err error) {
+			return cleanUpTest(ctx, testName, instance, r, reqLogger)
+		})
+		if err != nil {
+			reqLogger.Error(err, "Giving up on Cleanup", "TestName", strings.ToUpper(testName))
+			message := fmt.Sprintf("Coastie Operator: %s Cleanup failed. %s", strings.ToUpper(testName), err)
+			if err := notify(ctx, instance, r, testName, message); err != nil {
+				reqLogger.Error(err, "Failed to send slack message")
 			}
 		}
+		endSpan(span, err)
 	}
+}
 
+// knownTest returns true for the tests the operator knows how to run
+func knownTest(testName string) bool {
+	switch testName {
+	case "tcp", "udp", "http", "bandwidth", "mtu", "loadbalancing", "endpoints", "networkpolicy", "egress", "grpc", "websocket":
+		return true
+	}
+	return false
 }
 
-func runTest(testName string, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (retry bool) {
+// runTest runs the next phase of a test. It returns retry while the test has more phases to go through, and an
+// error when the phase failed, including when the probes or the rollout of the test failed
+func runTest(ctx context.Context, testName string, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (retry bool, err error) {
 	switch testName {
-	case "tcp":
-		err, retry := runTcpUdpTest(instance, r, reqLogger, "tcp")
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "TCP test encountered an error: ")
-		}
-		return retry
-	case "udp":
-		err, retry := runTcpUdpTest(instance, r, reqLogger, "udp")
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "UDP test encountered an error: ")
-		}
-		return retry
+	case "tcp", "udp":
+		err, retry = runTcpUdpTest(ctx, instance, r, reqLogger, testName)
 	case "http":
-		err, retry := runHttpTest(instance, r, reqLogger)
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "HTTP test encountered an error: ")
-		}
-		return retry
+		err, retry = runHttpTest(ctx, instance, r, reqLogger)
+	case "bandwidth":
+		err, retry = runBandwidthTest(ctx, instance, r, reqLogger)
+	case "mtu":
+		err, retry = runMTUTest(ctx, instance, r, reqLogger)
+	case "loadbalancing":
+		err, retry = runLoadBalancingTest(ctx, instance, r, reqLogger)
+	case "endpoints":
+		err, retry = runEndpointsTest(ctx, instance, r, reqLogger)
+	case "networkpolicy":
+		err, retry = runNetworkPolicyTest(ctx, instance, r, reqLogger)
+	case "egress":
+		err, retry = runEgressTest(ctx, instance, r, reqLogger)
+	case "grpc":
+		err, retry = runGRPCTest(ctx, instance, r, reqLogger)
+	case "websocket":
+		err, retry = runWebSocketTest(ctx, instance, r, reqLogger)
 	}
-	// Shouldnt reach here unless an an invalid case was passed
-	retry = false
-	return retry
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("%s test encountered an error: ", strings.ToUpper(testName)))
+		return true, err
+	}
+	return retry, nil
 }
 
-func cleanUpTest(testName string, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (retry bool) {
+func cleanUpTest(ctx context.Context, testName string, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (retry bool, err error) {
 	switch testName {
-	case "tcp":
-		err := deleteTcpUdpTest(instance, r, reqLogger, "tcp")
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "TCP Cleanup encountered an error: ")
-		}
-		return retry
-	case "udp":
-		err := deleteTcpUdpTest(instance, r, reqLogger, "udp")
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "UDP Cleanup encountered an error: ")
-		}
-		return retry
+	case "tcp", "udp":
+		err = deleteTcpUdpTest(ctx, instance, r, reqLogger, testName)
 	case "http":
-		err := deleteHttpTest(instance, r, reqLogger)
-		if err != nil {
-			retry = true
-			reqLogger.Error(err, "HTTP Cleanup encountered an error: ")
-		}
-		return retry
+		err = deleteHttpTest(ctx, instance, r, reqLogger)
+	case "bandwidth":
+		err = deleteBandwidthTest(ctx, instance, r, reqLogger)
+	case "mtu":
+		err = deleteMTUTest(ctx, instance, r, reqLogger)
+	case "loadbalancing":
+		err = deleteLoadBalancingTest(ctx, instance, r, reqLogger)
+	case "endpoints":
+		err = deleteEndpointsTest(ctx, instance, r, reqLogger)
+	case "networkpolicy":
+		err = deleteNetworkPolicyTest(ctx, instance, r, reqLogger)
+	case "egress":
+		err = deleteEgressTest(ctx, instance, r, reqLogger)
+	case "grpc":
+		err = deleteGRPCTest(ctx, instance, r, reqLogger)
+	case "websocket":
+		err = deleteWebSocketTest(ctx, instance, r, reqLogger)
+	}
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("%s Cleanup encountered an error: ", strings.ToUpper(testName)))
+		return true, err
+	}
+	return false, nil
+}
+
+// namespacePredicate filters out the events of objects outside of the watched namespaces
+func namespacePredicate(cfg *config.Config) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return cfg.Watches(e.Meta.GetNamespace())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return cfg.Watches(e.Meta.GetNamespace())
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return cfg.Watches(e.MetaNew.GetNamespace())
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return cfg.Watches(e.Meta.GetNamespace())
+		},
 	}
-	// Shouldnt reach here unless an an invalid case was passed
-	retry = false
-	return retry
 }
@@ -0,0 +1,149 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Timeout of every probe of the egress test when the test does not set it
+const defaultEgressTimeout = 5 * time.Second
+
+// egressSettings of the egress test, with the defaults applied
+type egressSettings struct {
+	targets []k8sv1alpha1.EgressTarget
+	timeout time.Duration
+}
+
+// testEgressSettings returns the targets of the egress test, named after their address when they have no name
+func testEgressSettings(instance *k8sv1alpha1.Coastie) (settings egressSettings, err error) {
+	settings.timeout = defaultEgressTimeout
+	egress := testSettings(instance, "egress").Egress
+	if egress == nil || len(egress.Targets) == 0 {
+		return settings, fmt.Errorf("invalid egress settings: targets must list at least one target")
+	}
+	seen := make(map[string]bool)
+	for _, target := range egress.Targets {
+		if target.Address == "" {
+			return settings, fmt.Errorf("invalid egress settings: target %s has no address", target.Name)
+		}
+		switch target.Type {
+		case agent.EgressTCP:
+			if _, _, err := net.SplitHostPort(target.Address); err != nil {
+				return settings, fmt.Errorf("invalid egress settings: tcp address %s must be a host:port", target.Address)
+			}
+		case agent.EgressHTTP:
+			u, err := url.Parse(target.Address)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return settings, fmt.Errorf("invalid egress settings: http address %s must be an http or https URL", target.Address)
+			}
+		case agent.EgressDNS:
+		default:
+			return settings, fmt.Errorf("invalid egress settings: type %s of target %s must be %s, %s or %s", target.Type, target.Address, agent.EgressTCP, agent.EgressHTTP, agent.EgressDNS)
+		}
+		if target.Type != agent.EgressHTTP && len(target.StatusCodes) > 0 {
+			return settings, fmt.Errorf("invalid egress settings: statuscodes only applies to http targets")
+		}
+		for _, code := range target.StatusCodes {
+			if code < 100 || code > 599 {
+				return settings, fmt.Errorf("invalid egress settings: status code %d must be between 100 and 599", code)
+			}
+		}
+		if target.Name == "" {
+			target.Name = target.Address
+		}
+		if seen[target.Name] {
+			return settings, fmt.Errorf("invalid egress settings: target %s is set twice", target.Name)
+		}
+		seen[target.Name] = true
+		settings.targets = append(settings.targets, target)
+	}
+	durationOrDefault(&settings.timeout, egress.Timeout)
+	if settings.timeout > agent.MaxTimeout {
+		return settings, fmt.Errorf("invalid egress settings: timeout %s must be at most %s", settings.timeout, agent.MaxTimeout)
+	}
+	return settings, nil
+}
+
+func runEgressTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	settings, err := testEgressSettings(instance)
+	if err != nil {
+		return err, retry
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "egress", nil, func(ctx context.Context, c *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		return egressProbe(ctx, c, instance, reqLogger, settings, pods)
+	})
+}
+
+// egressProbe has the agent on every node probe every target, the metric named after a target tells whether
+// the node reached it. The latencies are the durations of the probes reaching their target
+func egressProbe(ctx context.Context, c *agent.Client, instance *k8sv1alpha1.Coastie, reqLogger logr.Logger, settings egressSettings, pods []corev1.Pod) (status string, latencies []time.Duration, nodes []k8sv1alpha1.NodeResult) {
+	var failed []string
+	for _, pod := range pods {
+		node := k8sv1alpha1.NodeResult{
+			NodeName: pod.Spec.NodeName,
+			PodName:  pod.Name,
+			Status:   "Passed",
+			Metrics:  map[string]string{},
+		}
+		var problems []string
+		for _, target := range settings.targets {
+			_, span := startTestSpan(ctx, "Probe target", instance, "egress", tracing.NodeKey.String(pod.Spec.NodeName), tracing.TargetKey.String(target.Address))
+			result, err := c.Egress(ctx, pod.Status.PodIP, target.Type, target.Address, settings.timeout)
+			targetStatus := "SUCCESS"
+			switch {
+			case err != nil:
+				targetStatus = fmt.Sprintf("ERROR: agent did not answer: %s", err)
+			case !result.Reachable:
+				targetStatus = fmt.Sprintf("ERROR: %s", result.Error)
+			case !expectedStatus(target.StatusCodes, result.StatusCode):
+				targetStatus = fmt.Sprintf("ERROR: status code %d, expected %v", result.StatusCode, target.StatusCodes)
+			}
+			endProbeSpan(span, targetStatus)
+			reqLogger.Info("Egress from node", "Node", pod.Spec.NodeName, "Target", target.Name, "Type", target.Type, "Duration", result.Duration, "Status", targetStatus)
+			if targetStatus == "SUCCESS" {
+				node.Metrics[target.Name] = "Passed"
+				latencies = append(latencies, result.Duration)
+				continue
+			}
+			node.Metrics[target.Name] = "Failed"
+			problems = append(problems, fmt.Sprintf("%s: %s", target.Name, strings.TrimPrefix(targetStatus, "ERROR: ")))
+		}
+		if len(problems) > 0 {
+			node.Status = "Failed"
+			node.Message = strings.Join(problems, ", ")
+			failed = append(failed, fmt.Sprintf("%s (%s)", pod.Spec.NodeName, node.Message))
+		}
+		nodes = append(nodes, node)
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("ERROR: EGRESS Failed from nodes: %s", strings.Join(failed, ", ")), latencies, nodes
+	}
+	return fmt.Sprintf("SUCCESS: EGRESS reached %d targets from every node", len(settings.targets)), latencies, nodes
+}
+
+// expectedStatus returns true when statusCode is among statusCodes, or statusCodes is empty
+func expectedStatus(statusCodes []int, statusCode int) bool {
+	if len(statusCodes) == 0 {
+		return true
+	}
+	for _, code := range statusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func deleteEgressTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	return deleteAgentTest(ctx, instance, r, reqLogger, "egress")
+}
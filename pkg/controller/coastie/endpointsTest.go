@@ -0,0 +1,384 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Settings of the endpoints test used when the test does not set them
+const (
+	defaultMaxEndpointDelay = 5 * time.Second
+	defaultMaxAnswerDelay   = 10 * time.Second
+)
+
+// Wait between two looks at the endpoints and the answers of the pods, bounding the precision of the delays
+const endpointsPollInterval = 500 * time.Millisecond
+
+// Most requests sent through the ClusterIP at every look, looking for the pods which did not answer yet
+const maxEndpointsRequests = 100
+
+// endpointsSettings of the endpoints test, with the defaults applied
+type endpointsSettings struct {
+	maxEndpointDelay time.Duration
+	maxAnswerDelay   time.Duration
+}
+
+// testEndpointsSettings returns the settings of the endpoints test
+func testEndpointsSettings(instance *k8sv1alpha1.Coastie) endpointsSettings {
+	settings := endpointsSettings{
+		maxEndpointDelay: defaultMaxEndpointDelay,
+		maxAnswerDelay:   defaultMaxAnswerDelay,
+	}
+	if endpoints := testSettings(instance, "endpoints").Endpoints; endpoints != nil {
+		durationOrDefault(&settings.maxEndpointDelay, endpoints.MaxEndpointDelay)
+		durationOrDefault(&settings.maxAnswerDelay, endpoints.MaxAnswerDelay)
+	}
+	return settings
+}
+
+// podPropagation is when a pod became Ready, and was first seen in the endpoints and answering
+type podPropagation struct {
+	pod      corev1.Pod
+	ready    time.Time
+	endpoint time.Time
+	answer   time.Time
+}
+
+func runEndpointsTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	retry = false
+	name := fmt.Sprintf("%s-endpoints", instance.Name)
+	timeouts := testTimeouts(instance, r, "endpoints")
+	settings := testEndpointsSettings(instance)
+	if _, err := testLatencyThresholds(instance, "endpoints"); err != nil {
+		return err, retry
+	}
+	// Every phase the test goes through gets its own span, the current one is ended on return
+	testCtx := ctx
+	ctx, span := startTestSpan(testCtx, "DaemonSet", instance, "endpoints")
+	defer func() { endSpan(span, err) }()
+	// The agents need the Secret holding the token of their control API
+	if _, err := ensureAgentToken(ctx, instance, r, reqLogger); err != nil {
+		return err, retry
+	}
+	// Define a new DaemonSet object
+	agentDaemonSet := agentServer(instance, name, r.config.AgentImage)
+	// Set Coastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, agentDaemonSet, r.scheme); err != nil {
+		return err, retry
+	}
+
+	// Check if this DaemonSet already exists
+	TestStatus := instance.Status.TestResults["endpoints"]
+	found := &appsv1.DaemonSet{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		// The Service comes first, so it selects every pod from the moment it is created
+		err = createIfNotFound(ctx, instance, r, reqLogger, whoamiService(instance, name))
+		if err != nil {
+			return err, retry
+		}
+		reqLogger.Info("Creating a new DaemonSet", "DaemonSet.Namespace", agentDaemonSet.Namespace, "DaemonSet.Name", name)
+		err = r.client.Create(ctx, agentDaemonSet)
+		if err != nil {
+			return err, retry
+		}
+		// DaemonSet created successfully - return and requeue
+		dsct := time.Now().Format(time.RFC3339)
+		TestStatus.DaemonSetCreationTime = dsct
+		TestStatus.Status = "Running"
+		TestStatus.Latency = nil
+		err = updateCoastieStatus(instance, TestStatus, "endpoints", reqLogger, r)
+		if err != nil {
+			return err, retry
+		}
+		retry = true
+		return nil, retry
+	} else if err != nil {
+		return err, retry
+	}
+	if found.DeletionTimestamp != nil {
+		// A failed attempt is still deleting its DaemonSet, the next step creates a new one once it is gone
+		err = waitForDaemonSetDeletion(ctx, r, reqLogger, found.Name, found.Namespace, timeouts.rollout)
+		if err != nil {
+			return err, retry
+		}
+		retry = true
+		return nil, retry
+	}
+
+	// Follow the pods as they become Ready, until every one of them answers or the rollout timeout is hit
+	span.End()
+	ctx, span = startTestSpan(testCtx, "Propagation", instance, "endpoints")
+	service := &corev1.Service{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, service)
+	if err != nil && errors.IsNotFound(err) {
+		// The cache has yet to see the Service created along with the DaemonSet
+		retry = true
+		return nil, retry
+	} else if err != nil {
+		return err, retry
+	}
+	deadline := time.Now().Add(timeouts.rollout)
+	if dsct, err := time.Parse(time.RFC3339, instance.Status.TestResults["endpoints"].DaemonSetCreationTime); err == nil {
+		deadline = dsct.Add(timeouts.rollout)
+	}
+	pods := watchPropagation(ctx, r, reqLogger, found, service, timeouts, deadline)
+
+	var nodes []k8sv1alpha1.NodeResult
+	var failed []string
+	for _, p := range pods {
+		node := k8sv1alpha1.NodeResult{
+			NodeName: p.pod.Spec.NodeName,
+			PodName:  p.pod.Name,
+			Status:   "Passed",
+			Metrics:  map[string]string{},
+		}
+		var problem string
+		switch {
+		case p.ready.IsZero():
+			problem = "pod did not become Ready"
+		case p.endpoint.IsZero():
+			problem = "pod was not added to the endpoints of the Service"
+		case p.answer.IsZero():
+			problem = "pod did not answer through the ClusterIP"
+		}
+		if !p.endpoint.IsZero() {
+			delay := propagationDelay(p.ready, p.endpoint)
+			node.Metrics["endpointdelay"] = delay.String()
+			if problem == "" && delay > settings.maxEndpointDelay {
+				problem = fmt.Sprintf("pod was added to the endpoints %s after becoming Ready, above %s", delay, settings.maxEndpointDelay)
+			}
+		}
+		if !p.answer.IsZero() {
+			delay := propagationDelay(p.ready, p.answer)
+			node.Metrics["answerdelay"] = delay.String()
+			if problem == "" && delay > settings.maxAnswerDelay {
+				problem = fmt.Sprintf("pod answered through the ClusterIP %s after becoming Ready, above %s", delay, settings.maxAnswerDelay)
+			}
+		}
+		if problem != "" {
+			node.Status = "Failed"
+			node.Message = problem
+			failed = append(failed, p.pod.Spec.NodeName)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeName < nodes[j].NodeName })
+	reqLogger.Info("Endpoint propagation", "Nodes", nodes)
+	if len(pods) == 0 || len(failed) > 0 {
+		status := fmt.Sprintf("ERROR: ENDPOINTS propagation Failed on nodes: %s", failed)
+		if len(pods) == 0 {
+			status = "ERROR: ENDPOINTS no agent pod was scheduled"
+		}
+		// The pods went Ready during this attempt, the next one needs new pods to measure their propagation
+		err = deleteAgentTest(ctx, instance, r, reqLogger, "endpoints")
+		if err == nil {
+			err = waitForDaemonSetDeletion(ctx, r, reqLogger, name, instance.Namespace, timeouts.rollout)
+		}
+		if err != nil {
+			reqLogger.Error(err, "Failed to delete the DaemonSet before the next attempt", "DaemonSet.Namespace", instance.Namespace, "DaemonSet.Name", name)
+		}
+		return failTest(instance, r, reqLogger, "endpoints", TestStatus, status, nodes)
+	}
+	TestStatus.Status = "Passed"
+	err = completeTest(ctx, instance, r, reqLogger, "endpoints", TestStatus, "", nodes)
+	if err != nil {
+		return err, retry
+	}
+	reqLogger.Info("Reached end of Test", "TestName", "ENDPOINTS")
+	return nil, retry
+}
+
+// watchPropagation looks at the pods of ds, the endpoints of service and who answers through its ClusterIP
+// every endpointsPollInterval, until every pod scheduled answers or deadline is hit
+func watchPropagation(ctx context.Context, r *ReconcileCoastie, reqLogger logr.Logger, ds *appsv1.DaemonSet, service *corev1.Service, timeouts timeouts, deadline time.Time) (pods map[string]*podPropagation) {
+	pods = make(map[string]*podPropagation)
+	whoamiClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       (&net.Dialer{Timeout: timeouts.dial}).DialContext,
+			DisableKeepAlives: true,
+		},
+		Timeout: timeouts.dial + timeouts.read,
+	}
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(service.Spec.ClusterIP, "80"), loadBalancingPath)
+	for {
+		for _, pod := range listTestPods(r, ds.Name, ds.Namespace) {
+			p, ok := pods[pod.Name]
+			if !ok {
+				p = &podPropagation{}
+				pods[pod.Name] = p
+			}
+			p.pod = pod
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue && p.ready.IsZero() {
+					p.ready = condition.LastTransitionTime.Time
+				}
+			}
+		}
+
+		ips, err := endpointIPs(ctx, r, service.Namespace, service.Name)
+		if err != nil {
+			reqLogger.Error(err, "Failed to read the endpoints", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		}
+		now := time.Now()
+		pending := 0
+		for _, p := range pods {
+			if p.endpoint.IsZero() && p.pod.Status.PodIP != "" && ips[p.pod.Status.PodIP] {
+				p.endpoint = now
+			}
+			if !p.endpoint.IsZero() && p.answer.IsZero() {
+				pending++
+			}
+		}
+		// Twice as many requests as pods left to answer gives every one of them a fair chance to
+		requests := 2 * pending
+		if requests > maxEndpointsRequests {
+			requests = maxEndpointsRequests
+		}
+		for i := 0; i < requests; i++ {
+			who, err := whoami(whoamiClient, url)
+			if err != nil {
+				continue
+			}
+			if p, ok := pods[who.Pod]; ok && p.answer.IsZero() {
+				p.answer = time.Now()
+			}
+		}
+
+		done := len(pods) > 0 && int32(len(pods)) >= ds.Status.DesiredNumberScheduled
+		for _, p := range pods {
+			if p.answer.IsZero() {
+				done = false
+			}
+		}
+		if done || time.Now().After(deadline) {
+			return pods
+		}
+		time.Sleep(endpointsPollInterval)
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}, ds); err != nil {
+			reqLogger.Error(err, "Failed to get DaemonSet status", "DaemonSet.Namespace", ds.Namespace, "DaemonSet.Name", ds.Name)
+		}
+	}
+}
+
+// waitForDaemonSetDeletion waits until the DaemonSet name and its pods are gone, so the pods of the next
+// DaemonSet are the only ones followed
+func waitForDaemonSetDeletion(ctx context.Context, r *ReconcileCoastie, reqLogger logr.Logger, name, namespace string, timeout time.Duration) error {
+	polls, interval := rolloutPolls(timeout)
+	for i := 0; i < polls; i++ {
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &appsv1.DaemonSet{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if errors.IsNotFound(err) && len(listTestPods(r, name, namespace)) == 0 {
+			return nil
+		}
+		reqLogger.Info("Waiting for the DaemonSet to be deleted", "DaemonSet.Namespace", namespace, "DaemonSet.Name", name)
+		time.Sleep(interval)
+	}
+	return fmt.Errorf("DaemonSet %s was not deleted within %s", name, timeout)
+}
+
+// propagationDelay returns the time from ready to seen. Ready conditions only have a precision of a second, a
+// pod seen within the second it became Ready has no delay
+func propagationDelay(ready, seen time.Time) time.Duration {
+	delay := seen.Sub(ready)
+	if delay < 0 {
+		return 0
+	}
+	return delay.Round(time.Millisecond)
+}
+
+// endpointIPs returns the addresses of the ready endpoints of service, read from its EndpointSlices, or from its
+// Endpoints on clusters without EndpointSlices
+func endpointIPs(ctx context.Context, r *ReconcileCoastie, namespace, service string) (ips map[string]bool, err error) {
+	ips = make(map[string]bool)
+	for _, version := range []string{"discovery.k8s.io/v1", "discovery.k8s.io/v1beta1"} {
+		opts := &client.ListOptions{}
+		opts.SetLabelSelector(fmt.Sprintf("kubernetes.io/service-name=%s", service))
+		opts.InNamespace(namespace)
+		list := &unstructured.UnstructuredList{}
+		list.SetAPIVersion(version)
+		list.SetKind("EndpointSliceList")
+		err = r.client.List(ctx, opts, list)
+		if meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return ips, err
+		}
+		for _, slice := range list.Items {
+			endpoints, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+			for _, v := range endpoints {
+				endpoint, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				// An endpoint without a ready condition is ready
+				if ready, found, _ := unstructured.NestedBool(endpoint, "conditions", "ready"); found && !ready {
+					continue
+				}
+				addresses, _, _ := unstructured.NestedStringSlice(endpoint, "addresses")
+				for _, address := range addresses {
+					ips[address] = true
+				}
+			}
+		}
+		return ips, nil
+	}
+
+	endpoints := &unstructured.Unstructured{}
+	endpoints.SetAPIVersion("v1")
+	endpoints.SetKind("Endpoints")
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: service}, endpoints)
+	if errors.IsNotFound(err) {
+		return ips, nil
+	} else if err != nil {
+		return ips, err
+	}
+	subsets, _, _ := unstructured.NestedSlice(endpoints.Object, "subsets")
+	for _, v := range subsets {
+		subset, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Addresses are ready, notReadyAddresses are not
+		addresses, _, _ := unstructured.NestedSlice(subset, "addresses")
+		for _, a := range addresses {
+			if address, ok := a.(map[string]interface{}); ok {
+				if ip, ok := address["ip"].(string); ok {
+					ips[ip] = true
+				}
+			}
+		}
+	}
+	return ips, nil
+}
+
+func deleteEndpointsTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	name := fmt.Sprintf("%s-endpoints", instance.Name)
+	err = deleteAgentTest(ctx, instance, r, reqLogger, "endpoints")
+	if err != nil {
+		return err
+	}
+	// Delete Service
+	err = r.client.Delete(ctx, whoamiService(instance, name))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
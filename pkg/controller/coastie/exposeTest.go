@@ -0,0 +1,253 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// How long the LoadBalancer Service has to get an external IP when the test does not set it
+const defaultLoadBalancerTimeout = 5 * time.Minute
+
+// Wait between two checks of the external IP of the LoadBalancer Service, short enough to measure its allocation
+const loadBalancerPollInterval = 2 * time.Second
+
+// loadBalancerWatch follows a LoadBalancer Service in the background until it gets an external address, its
+// timeout is hit or it is stopped
+type loadBalancerWatch struct {
+	uid        types.UID
+	cancel     context.CancelFunc
+	done       chan struct{}
+	address    string
+	allocation time.Duration
+}
+
+// loadBalancerWatches are the watches of the LoadBalancer Services, by namespace and name. A watch is stopped and
+// forgotten when its Service is deleted
+var loadBalancerWatches = struct {
+	sync.Mutex
+	watches map[types.NamespacedName]*loadBalancerWatch
+}{watches: make(map[types.NamespacedName]*loadBalancerWatch)}
+
+// exposeSettings of the tcp or udp test, with the defaults applied
+type exposeSettings struct {
+	nodePort            bool
+	loadBalancer        bool
+	loadBalancerTimeout time.Duration
+}
+
+// testExposeSettings returns the external Services a test probes, nil when it probes none
+func testExposeSettings(instance *k8sv1alpha1.Coastie, testName string) (settings *exposeSettings, err error) {
+	expose := testSettings(instance, testName).Expose
+	if expose == nil || (!expose.NodePort && !expose.LoadBalancer) {
+		return nil, nil
+	}
+	if testName != "tcp" && testName != "udp" {
+		return nil, fmt.Errorf("invalid %s settings: expose only applies to the tcp and udp tests", testName)
+	}
+	if tcpudp := testSettings(instance, testName).TcpUdp; tcpudp != nil && tcpudp.Host != "" {
+		return nil, fmt.Errorf("invalid %s settings: expose needs the bundled %s servers and can not be set along with host", testName, testName)
+	}
+	settings = &exposeSettings{
+		nodePort:            expose.NodePort,
+		loadBalancer:        expose.LoadBalancer,
+		loadBalancerTimeout: defaultLoadBalancerTimeout,
+	}
+	durationOrDefault(&settings.loadBalancerTimeout, expose.LoadBalancerTimeout)
+	return settings, nil
+}
+
+// services returns the external Services in front of the tcp or udp servers of the test
+func (settings *exposeSettings) services(cr *k8sv1alpha1.Coastie, name, tcpudp string) (services []*corev1.Service) {
+	if settings == nil {
+		return nil
+	}
+	if settings.nodePort {
+		services = append(services, tcpudpExternalService(cr, name, tcpudp, corev1.ServiceTypeNodePort))
+	}
+	if settings.loadBalancer {
+		services = append(services, tcpudpExternalService(cr, name, tcpudp, corev1.ServiceTypeLoadBalancer))
+	}
+	return services
+}
+
+// tcpudpExternalService returns a Service of serviceType in front of the tcp or udp servers
+func tcpudpExternalService(cr *k8sv1alpha1.Coastie, name, tcpudp string, serviceType corev1.ServiceType) *corev1.Service {
+	service := tcpudpServerService(cr, fmt.Sprintf("%s-%s", name, strings.ToLower(string(serviceType))), tcpudp)
+	service.Spec.Type = serviceType
+	service.Spec.Selector = map[string]string{
+		"app": name,
+	}
+	return service
+}
+
+// probeNodePorts probes the port of the NodePort Service on the IP of every node, and adds the outcome to the
+// node results. It returns the node results along with the nodes failing
+func probeNodePorts(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, name, tcpudp string, probe tcpudpProbe, timeouts timeouts, nodes []k8sv1alpha1.NodeResult) ([]k8sv1alpha1.NodeResult, []string) {
+	service := tcpudpExternalService(instance, name, tcpudp, corev1.ServiceTypeNodePort)
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, service)
+	if err != nil || len(service.Spec.Ports) == 0 || service.Spec.Ports[0].NodePort == 0 {
+		reqLogger.Error(err, "NodePort Service has no port", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		return nodes, []string{fmt.Sprintf("NodePort Service %s has no port", service.Name)}
+	}
+	probe.port = service.Spec.Ports[0].NodePort
+	nodeList := &corev1.NodeList{}
+	err = r.client.List(ctx, &client.ListOptions{}, nodeList)
+	if err != nil {
+		return nodes, []string{fmt.Sprintf("listing nodes: %s", err)}
+	}
+
+	var failed []string
+	for _, v := range nodeList.Items {
+		ip := nodeAddress(v)
+		_, span := startTestSpan(ctx, "Probe node port", instance, tcpudp, tracing.NodeKey.String(v.Name), tracing.TargetKey.String(ip))
+		status := fmt.Sprintf("ERROR: %s node has no address", strings.ToUpper(tcpudp))
+		if ip != "" {
+			status = tcpudpClient(ip, tcpudp, probe, timeouts, reqLogger)
+		}
+		endProbeSpan(span, status)
+
+		// Nodes without a test pod still forward their node port
+		i := 0
+		for i < len(nodes) && nodes[i].NodeName != v.Name {
+			i++
+		}
+		if i == len(nodes) {
+			nodes = append(nodes, k8sv1alpha1.NodeResult{NodeName: v.Name, Status: "Passed"})
+		}
+		node := &nodes[i]
+		if node.Metrics == nil {
+			node.Metrics = map[string]string{}
+		}
+		if strings.Contains(status, "SUCCESS") {
+			node.Metrics["nodeport"] = "Passed"
+			continue
+		}
+		node.Metrics["nodeport"] = "Failed"
+		node.Status = "Failed"
+		if node.Message != "" {
+			node.Message += ", "
+		}
+		node.Message += fmt.Sprintf("NodePort %d: %s", probe.port, status)
+		failed = append(failed, v.Name)
+	}
+	return nodes, failed
+}
+
+// nodeAddress returns the internal IP of node, its external IP when it has none
+func nodeAddress(node corev1.Node) string {
+	address := ""
+	for _, v := range node.Status.Addresses {
+		switch v.Type {
+		case corev1.NodeInternalIP:
+			return v.Address
+		case corev1.NodeExternalIP:
+			address = v.Address
+		}
+	}
+	return address
+}
+
+// watchLoadBalancer starts following service, unless it is already followed, and returns its watch. Called
+// right after the Service is created, the poll interval bounds the allocation measured
+func watchLoadBalancer(r *ReconcileCoastie, reqLogger logr.Logger, service *corev1.Service, timeout time.Duration) *loadBalancerWatch {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+	loadBalancerWatches.Lock()
+	defer loadBalancerWatches.Unlock()
+	if w, ok := loadBalancerWatches.watches[key]; ok {
+		if w.uid == service.UID {
+			return w
+		}
+		// The Service of a previous run was deleted without going through the cleanup
+		w.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &loadBalancerWatch{
+		uid:    service.UID,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	loadBalancerWatches.watches[key] = w
+	go func() {
+		defer close(w.done)
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) {
+			found := &corev1.Service{}
+			// The cache may still hold the Service of a previous run, or none at all
+			err := r.client.Get(ctx, key, found)
+			if err == nil && found.UID == w.uid && len(found.Status.LoadBalancer.Ingress) > 0 {
+				address := found.Status.LoadBalancer.Ingress[0].IP
+				if address == "" {
+					address = found.Status.LoadBalancer.Ingress[0].Hostname
+				}
+				if address != "" {
+					w.address = address
+					w.allocation = time.Since(found.CreationTimestamp.Time)
+					reqLogger.Info("LoadBalancer Service got an external IP", "Service.Namespace", key.Namespace, "Service.Name", key.Name, "Address", address, "Allocation", w.allocation)
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(loadBalancerPollInterval):
+			}
+		}
+	}()
+	return w
+}
+
+// stopLoadBalancerWatch stops and forgets the watch of service, deleted along with the test
+func stopLoadBalancerWatch(service *corev1.Service) {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+	loadBalancerWatches.Lock()
+	defer loadBalancerWatches.Unlock()
+	if w, ok := loadBalancerWatches.watches[key]; ok {
+		w.cancel()
+		delete(loadBalancerWatches.watches, key)
+	}
+}
+
+// probeLoadBalancer waits for the watch of the LoadBalancer Service to see its external IP and probes it. It
+// returns how long the Service took to get its external IP since it was created
+func probeLoadBalancer(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, name, tcpudp string, probe tcpudpProbe, settings *exposeSettings, timeouts timeouts) (status string, allocation *time.Duration) {
+	service := tcpudpExternalService(instance, name, tcpudp, corev1.ServiceTypeLoadBalancer)
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, service)
+	if err != nil {
+		return fmt.Sprintf("ERROR: %s LoadBalancer Service not found: %s", strings.ToUpper(tcpudp), err), nil
+	}
+	// The watch started along with the Service, unless the operator restarted since
+	w := watchLoadBalancer(r, reqLogger, service, settings.loadBalancerTimeout)
+	select {
+	case <-w.done:
+	default:
+		reqLogger.Info("LoadBalancer Service has no external IP yet", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		<-w.done
+	}
+	if w.address == "" {
+		return fmt.Sprintf("ERROR: %s LoadBalancer got no external IP within %s", strings.ToUpper(tcpudp), settings.loadBalancerTimeout), nil
+	}
+	address := w.address
+	d := w.allocation
+	allocation = &d
+	// A new load balancer can take a while to pass its health checks
+	for i := 0; i < timeouts.probeAttempts; i++ {
+		_, span := startTestSpan(ctx, "Probe load balancer", instance, tcpudp, tracing.AttemptKey.Int(i), tracing.TargetKey.String(address))
+		status = tcpudpClient(address, tcpudp, probe, timeouts, reqLogger)
+		endProbeSpan(span, status)
+		if strings.Contains(status, "SUCCESS") {
+			return status, allocation
+		}
+		time.Sleep(timeouts.probeInterval)
+	}
+	return strings.Replace(status, "ERROR: ", "ERROR: LoadBalancer ", 1), allocation
+}
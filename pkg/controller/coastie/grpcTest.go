@@ -0,0 +1,265 @@
+package coastie
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	instr "k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// grpcHealthPath prefixes the path of every method of the gRPC health service, the Ingress only routes it
+const grpcHealthPath = "/grpc.health.v1.Health"
+
+func runGRPCTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	settings := k8sv1alpha1.GRPCTest{}
+	if grpcSettings := testSettings(instance, "grpc").GRPC; grpcSettings != nil {
+		settings = *grpcSettings
+	}
+	if settings.Service != "" && settings.Target == "" {
+		return fmt.Errorf("invalid grpc settings: service %s needs a target, the agents only know the server as a whole", settings.Service), retry
+	}
+	if settings.Target != "" {
+		if _, _, err := net.SplitHostPort(settings.Target); err != nil {
+			return fmt.Errorf("invalid grpc settings: target %s must be a host:port", settings.Target), retry
+		}
+	}
+	name := fmt.Sprintf("%s-grpc", instance.Name)
+	timeouts := testTimeouts(instance, r, "grpc")
+	// The Service and Ingress are created along with the agents
+	objects := []runtime.Object{grpcService(instance, name)}
+	if instance.Spec.HostURL != "" {
+		objects = append(objects, grpcIngress(instance, name))
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "grpc", objects, func(ctx context.Context, _ *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		return grpcProbe(ctx, instance, r, reqLogger, name, settings, timeouts, pods)
+	})
+}
+
+// grpcProbe checks the health of the agent on every node, then through the ClusterIP, or the target, and the
+// Ingress. The latencies are those of the checks of every node and path
+func grpcProbe(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, name string, settings k8sv1alpha1.GRPCTest, timeouts timeouts, pods []corev1.Pod) (status string, latencies []time.Duration, nodes []k8sv1alpha1.NodeResult) {
+	var failed []string
+	for _, pod := range pods {
+		node := k8sv1alpha1.NodeResult{
+			NodeName: pod.Spec.NodeName,
+			PodName:  pod.Name,
+		}
+		target := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(agent.GRPCPort))
+		_, span := startTestSpan(ctx, "Probe node", instance, "grpc", tracing.NodeKey.String(pod.Spec.NodeName), tracing.TargetKey.String(target))
+		serving, latency, err := grpcHealthCheck(ctx, target, "", nil, timeouts)
+		nodeStatus := grpcStatus(serving, err)
+		endProbeSpan(span, nodeStatus)
+		node.Metrics = map[string]string{"status": serving}
+		if strings.Contains(nodeStatus, "SUCCESS") {
+			node.Status = "Passed"
+			node.Latency = &metav1.Duration{Duration: latency}
+			latencies = append(latencies, latency)
+		} else {
+			node.Status = "Failed"
+			node.Message = nodeStatus
+			failed = append(failed, pod.Spec.NodeName)
+		}
+		nodes = append(nodes, node)
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("ERROR: GRPC Failed on nodes: %s", failed), latencies, nodes
+	}
+
+	// Then through the ClusterIP, or the target, and the Ingress
+	target := settings.Target
+	if target == "" {
+		service := &corev1.Service{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, service)
+		if err != nil {
+			return fmt.Sprintf("ERROR: GRPC Service not found: %s", err), latencies, nodes
+		}
+		target = net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(agent.GRPCPort))
+	}
+	var paths []string
+	for _, path := range grpcPaths(instance, target, settings) {
+		var serving string
+		var latency time.Duration
+		var err error
+		// A new Ingress takes a while to route
+		for i := 0; i < timeouts.probeAttempts; i++ {
+			_, span := startTestSpan(ctx, "Probe attempt", instance, "grpc", tracing.AttemptKey.Int(i), tracing.TargetKey.String(path.target))
+			serving, latency, err = grpcHealthCheck(ctx, path.target, path.service, path.tls, timeouts)
+			status = grpcStatus(serving, err)
+			endProbeSpan(span, status)
+			if strings.Contains(status, "SUCCESS") {
+				break
+			}
+			reqLogger.Info("Test client failed, sleeping and trying again", "ClientAttempt", i, "ProbeInterval", timeouts.probeInterval, "Path", path.name, "Target", path.target)
+			time.Sleep(timeouts.probeInterval)
+		}
+		reqLogger.Info("gRPC health check", "Path", path.name, "Target", path.target, "Serving", serving, "Latency", latency, "Status", status)
+		if !strings.Contains(status, "SUCCESS") {
+			return strings.Replace(status, "ERROR: GRPC Failed", fmt.Sprintf("ERROR: GRPC Failed through the %s", path.name), 1), latencies, nodes
+		}
+		latencies = append(latencies, latency)
+		paths = append(paths, fmt.Sprintf("the %s in %s", path.name, latency))
+	}
+	return fmt.Sprintf("SUCCESS: GRPC is serving through %s", strings.Join(paths, " and ")), latencies, nodes
+}
+
+// grpcPath is a way to the gRPC health service, TLS is nil for plaintext. Service is the name checked, only a
+// configured target knows other services than the server as a whole
+type grpcPath struct {
+	name    string
+	target  string
+	service string
+	tls     *tls.Config
+}
+
+// grpcPaths returns the paths the health service is checked through, the Ingress one when the Coastie has a
+// hosturl. gRPC needs HTTP/2, which ingress controllers serve over TLS
+func grpcPaths(instance *k8sv1alpha1.Coastie, target string, settings k8sv1alpha1.GRPCTest) (paths []grpcPath) {
+	if settings.Target != "" {
+		paths = append(paths, grpcPath{name: "target", target: target, service: settings.Service})
+	} else {
+		paths = append(paths, grpcPath{name: "clusterip", target: target})
+	}
+	if instance.Spec.HostURL != "" {
+		host, port, err := net.SplitHostPort(instance.Spec.HostURL)
+		if err != nil {
+			host, port = instance.Spec.HostURL, "443"
+		}
+		paths = append(paths, grpcPath{
+			name:   "ingress",
+			target: net.JoinHostPort(host, port),
+			tls:    &tls.Config{ServerName: host, InsecureSkipVerify: settings.InsecureSkipVerify},
+		})
+	}
+	return paths
+}
+
+// grpcHealthCheck calls grpc.health.v1.Health/Check on target for service, and returns the serving status along
+// with how long the call took once connected
+func grpcHealthCheck(ctx context.Context, target, service string, tlsConfig *tls.Config, timeouts timeouts) (serving string, latency time.Duration, err error) {
+	transport := grpc.WithInsecure()
+	if tlsConfig != nil {
+		transport = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeouts.dial)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, target, transport, grpc.WithReturnConnectionError())
+	if err != nil {
+		return "", 0, err
+	}
+	defer conn.Close()
+	checkCtx, cancel := context.WithTimeout(ctx, timeouts.read)
+	defer cancel()
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(checkCtx, &healthpb.HealthCheckRequest{Service: service})
+	latency = time.Since(start)
+	if err != nil {
+		return "", latency, err
+	}
+	return resp.Status.String(), latency, nil
+}
+
+// grpcStatus returns the status of a health check
+func grpcStatus(serving string, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("ERROR: GRPC Failed - %s", err)
+	case serving != healthpb.HealthCheckResponse_SERVING.String():
+		return fmt.Sprintf("ERROR: GRPC Failed - status is %s", serving)
+	}
+	return "SUCCESS: GRPC is serving"
+}
+
+// grpcService returns the Service in front of the gRPC health server of the agents
+func grpcService(cr *k8sv1alpha1.Coastie, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "grpc",
+					Protocol:   "TCP",
+					Port:       agent.GRPCPort,
+					TargetPort: instr.FromInt(agent.GRPCPort),
+				},
+			},
+			Selector: map[string]string{
+				"app": name,
+			},
+		},
+	}
+}
+
+// grpcIngress returns the Ingress routing the health service of the hosturl to the agents over gRPC
+func grpcIngress(cr *k8sv1alpha1.Coastie, name string) *extensionsv1beta1.Ingress {
+	return &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/backend-protocol": "GRPC",
+			},
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			TLS: []extensionsv1beta1.IngressTLS{
+				{
+					Hosts: []string{cr.Spec.HostURL},
+				},
+			},
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: cr.Spec.HostURL,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: grpcHealthPath,
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: name,
+										ServicePort: instr.FromInt(agent.GRPCPort),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func deleteGRPCTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	name := fmt.Sprintf("%s-grpc", instance.Name)
+	err = deleteAgentTest(ctx, instance, r, reqLogger, "grpc")
+	if err != nil {
+		return err
+	}
+	// Delete Service and Ingress
+	for _, object := range []runtime.Object{grpcService(instance, name), grpcIngress(instance, name)} {
+		err = r.client.Delete(ctx, object)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package coastie
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Largest response body read from an http probe
+const maxHttpBody = 1024 * 1024
+
+// httpProbe is the request an http probe sends, and the response it expects, decoded from the test settings
+type httpProbe struct {
+	scheme             string
+	path               string
+	method             string
+	headers            map[string]string
+	body               string
+	statusCodes        []int
+	bodyContains       string
+	bodyRegex          *regexp.Regexp
+	jsonPath           []httpJSONPath
+	followRedirects    bool
+	maxRedirects       int
+	insecureSkipVerify bool
+	// network restricts the probe to an IP family, tcp4 for example, any family is dialed when empty
+	network string
+}
+
+type httpJSONPath struct {
+	path   string
+	value  string
+	parser *jsonpath.JSONPath
+}
+
+// defaultHttpProbe is the question and answer of the bundled http server
+func defaultHttpProbe() httpProbe {
+	return httpProbe{
+		scheme:          "http",
+		path:            "/ruok",
+		method:          http.MethodGet,
+		statusCodes:     []int{http.StatusOK},
+		followRedirects: true,
+		maxRedirects:    10,
+	}
+}
+
+// newHttpProbe returns the probe sent to the hosturl of the http test
+func newHttpProbe(instance *k8sv1alpha1.Coastie) (probe httpProbe, err error) {
+	probe = defaultHttpProbe()
+	settings := testSettings(instance, "http").HTTP
+	if settings == nil {
+		return probe, nil
+	}
+
+	switch settings.Scheme {
+	case "":
+	case "http", "https":
+		probe.scheme = settings.Scheme
+	default:
+		return probe, fmt.Errorf("invalid http settings: scheme %s must be http or https", settings.Scheme)
+	}
+	if settings.Path != "" {
+		if !strings.HasPrefix(settings.Path, "/") {
+			return probe, fmt.Errorf("invalid http settings: path %s must start with /", settings.Path)
+		}
+		probe.path = settings.Path
+	}
+	if settings.Method != "" {
+		probe.method = strings.ToUpper(settings.Method)
+	}
+	probe.headers = settings.Headers
+	probe.body = settings.Body
+	if len(settings.StatusCodes) > 0 {
+		for _, code := range settings.StatusCodes {
+			if code < 100 || code > 599 {
+				return probe, fmt.Errorf("invalid http settings: status code %d must be between 100 and 599", code)
+			}
+		}
+		probe.statusCodes = settings.StatusCodes
+	}
+	probe.bodyContains = settings.BodyContains
+	if settings.BodyRegex != "" {
+		probe.bodyRegex, err = regexp.Compile(settings.BodyRegex)
+		if err != nil {
+			return probe, fmt.Errorf("invalid http settings: bodyregex: %s", err)
+		}
+	}
+	for _, assertion := range settings.JSONPath {
+		parser := jsonpath.New(assertion.Path)
+		err = parser.Parse(assertion.Path)
+		if err != nil {
+			return probe, fmt.Errorf("invalid http settings: jsonpath %s: %s", assertion.Path, err)
+		}
+		probe.jsonPath = append(probe.jsonPath, httpJSONPath{
+			path:   assertion.Path,
+			value:  assertion.Value,
+			parser: parser,
+		})
+	}
+	if settings.FollowRedirects != nil {
+		probe.followRedirects = *settings.FollowRedirects
+	}
+	if settings.MaxRedirects < 0 {
+		return probe, fmt.Errorf("invalid http settings: maxredirects %d can not be negative", settings.MaxRedirects)
+	}
+	if settings.MaxRedirects > 0 {
+		probe.maxRedirects = settings.MaxRedirects
+	}
+	probe.insecureSkipVerify = settings.InsecureSkipVerify
+	return probe, nil
+}
+
+// request builds the request of the probe sent to host
+func (probe httpProbe) request(host string) (*http.Request, error) {
+	url := fmt.Sprintf("%s://%s%s", probe.scheme, host, probe.path)
+	var body io.Reader
+	if probe.body != "" {
+		body = strings.NewReader(probe.body)
+	}
+	req, err := http.NewRequest(probe.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range probe.headers {
+		if http.CanonicalHeaderKey(key) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// checkRedirect stops following redirects when told to, or after maxRedirects
+func (probe httpProbe) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !probe.followRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= probe.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", probe.maxRedirects)
+	}
+	return nil
+}
+
+// transport returns the transport the probe is sent with
+func (probe httpProbe) transport(timeouts timeouts) *http.Transport {
+	dialer := &net.Dialer{Timeout: timeouts.dial}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if probe.network != "" {
+				network = probe.network
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+		ResponseHeaderTimeout: timeouts.read,
+	}
+	if probe.insecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// check returns why the response does not match what the probe expects, or an empty string if it does
+func (probe httpProbe) check(resp *http.Response) (problem string) {
+	statusOK := false
+	for _, code := range probe.statusCodes {
+		if resp.StatusCode == code {
+			statusOK = true
+			break
+		}
+	}
+	if !statusOK {
+		return fmt.Sprintf("StatusCode Returned was : %d, expected %v", resp.StatusCode, probe.statusCodes)
+	}
+	if probe.bodyContains == "" && probe.bodyRegex == nil && len(probe.jsonPath) == 0 {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHttpBody))
+	if err != nil {
+		return fmt.Sprintf("Reading body: %s", err)
+	}
+	if probe.bodyContains != "" && !bytes.Contains(body, []byte(probe.bodyContains)) {
+		return fmt.Sprintf("Body does not contain %q", probe.bodyContains)
+	}
+	if probe.bodyRegex != nil && !probe.bodyRegex.Match(body) {
+		return fmt.Sprintf("Body does not match %q", probe.bodyRegex.String())
+	}
+	if len(probe.jsonPath) == 0 {
+		return ""
+	}
+	var data interface{}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return fmt.Sprintf("Body is not JSON: %s", err)
+	}
+	for _, assertion := range probe.jsonPath {
+		var out bytes.Buffer
+		err = assertion.parser.Execute(&out, data)
+		if err != nil {
+			return fmt.Sprintf("JSONPath %s: %s", assertion.path, err)
+		}
+		if assertion.value != "" && out.String() != assertion.value {
+			return fmt.Sprintf("JSONPath %s was %q, expected %q", assertion.path, out.String(), assertion.value)
+		}
+	}
+	return ""
+}
@@ -3,12 +3,14 @@ package coastie
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
@@ -20,9 +22,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+func runHttpTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
 	retry = false
 	name := fmt.Sprintf("%s-http", instance.Name)
+	timeouts := testTimeouts(instance, r, "http")
+	probe, err := newHttpProbe(instance)
+	if err != nil {
+		return err, retry
+	}
+	families, err := testIPFamilies(instance, "http")
+	if err != nil {
+		return err, retry
+	}
+	// Every phase the test goes through gets its own span, the current one is ended on return
+	testCtx := ctx
+	ctx, span := startTestSpan(testCtx, "DaemonSet", instance, "http")
+	defer func() { endSpan(span, err) }()
 	// Define a new DaemonSet object
 	httpDaemonSet := httpServer(instance, name)
 	// Set Coastie instance as the owner and controller
@@ -33,10 +48,10 @@ func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger l
 	// Check if this DaemonSet already exists
 	TestStatus := instance.Status.TestResults["http"]
 	found := &appsv1.DaemonSet{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
 	if err != nil && errors.IsNotFound(err) {
 		reqLogger.Info("Creating a new DaemonSet", "DaemonSet.Namespace", httpDaemonSet.Namespace, "DaemonSet.Name", name)
-		err = r.client.Create(context.TODO(), httpDaemonSet)
+		err = r.client.Create(ctx, httpDaemonSet)
 		if err != nil {
 			return err, retry
 		}
@@ -46,6 +61,7 @@ func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger l
 
 		TestStatus.DaemonSetCreationTime = dsct
 		TestStatus.Status = "Running"
+		TestStatus.Latency = nil
 		err = updateCoastieStatus(instance, TestStatus, "http", reqLogger, r)
 		if err != nil {
 			return err, retry
@@ -62,35 +78,37 @@ func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger l
 	if found.Status.DesiredNumberScheduled == found.Status.NumberReady {
 		// All pods are now running, run test against them
 		// Spin up service
+		span.End()
+		ctx, span = startTestSpan(testCtx, "Service", instance, "http")
 		httpService := httpServerService(instance, name)
 		// Set Coastie instance as the owner and controller
 		if err := controllerutil.SetControllerReference(instance, httpService, r.scheme); err != nil {
 			return err, retry
 		}
 		// Check if Service exists
-		err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, httpService)
-		if err != nil && errors.IsNotFound(err) {
-			reqLogger.Info("Creating a new Service", "Service.Namespace", httpService.Namespace, "Service.Name", name)
-			err = r.client.Create(context.TODO(), httpService)
-			if err != nil {
-				return err, retry
-			}
+		clusterIPs, created, err := getOrCreateService(ctx, r, reqLogger, httpService, families)
+		if err != nil {
+			return err, retry
+		}
+		if created {
 			// Service created successfully - return and requeue
 			retry = true
 			return nil, retry
 		}
 		// Service Exists
 		// Spin up ingress
+		span.End()
+		ctx, span = startTestSpan(testCtx, "Ingress", instance, "http")
 		httpIngress := httpServerIngress(instance, name)
 		// Set Coastie instance as the owner and controller
 		if err := controllerutil.SetControllerReference(instance, httpIngress, r.scheme); err != nil {
 			return err, retry
 		}
 		// Check if Ingress exists
-		err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, httpIngress)
+		err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, httpIngress)
 		if err != nil && errors.IsNotFound(err) {
 			reqLogger.Info("Creating a new Ingress", "Ingress.Namespace", httpIngress.Namespace, "Ingress.Name", name)
-			err = r.client.Create(context.TODO(), httpIngress)
+			err = r.client.Create(ctx, httpIngress)
 			if err != nil {
 				return err, retry
 			}
@@ -99,56 +117,86 @@ func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger l
 			return nil, retry
 		}
 		// Ingress Exists, how do we connect to it?
-		// Use client to connect to service, try 5 times if fail
-		// If this is still true later, fail with message
-		httpFail := true
+		span.End()
+		ctx, span = startTestSpan(testCtx, "Probe", instance, "http")
+		// Use client to connect to the Ingress, try again if fail. The Ingress is dialed over whatever family its
+		// host resolves to, the families of the test are probed on the Service
+		var probeLatency time.Duration
 		httpStatus := ""
 		i := 0
-		for i < 5 {
-			httpStatus = httpClient(instance.Spec.HostURL)
+		for i < timeouts.probeAttempts {
+			_, attemptSpan := startTestSpan(ctx, "Probe attempt", instance, "http", tracing.AttemptKey.Int(i), tracing.TargetKey.String(instance.Spec.HostURL))
+			start := time.Now()
+			httpStatus = httpClient(instance.Spec.HostURL, probe, timeouts)
+			probeLatency = time.Since(start)
+			endProbeSpan(attemptSpan, httpStatus)
 			if strings.Contains(httpStatus, "SUCCESS") {
-				TestStatus.Status = "Passed"
-				err = updateCoastieStatus(instance, TestStatus, "http", reqLogger, r)
-				if err != nil {
-					return err, retry
-				}
-				httpFail = false
 				// Exit loop
-				i = 5
+				i = timeouts.probeAttempts
 			} else {
 				// Pods are running, but failing test, give them a few seconds
-				reqLogger.Info("Test client failed, sleeping for 6 seconds and trying again", "ClientAttempt", i, "Service.Namespace", found.Namespace, "Service.Name", name)
+				reqLogger.Info("Test client failed, sleeping and trying again", "ClientAttempt", i, "ProbeInterval", timeouts.probeInterval, "Service.Namespace", found.Namespace, "Service.Name", name)
 				i++
-				time.Sleep(6 * time.Second)
+				time.Sleep(timeouts.probeInterval)
 			}
 		}
+		// If this is still true later, fail with message
+		httpFail := !strings.Contains(httpStatus, "SUCCESS")
+		// Connect to the ClusterIP of every family the test asked for, these probes always ask the bundled
+		// http server
+		if !httpFail && families[0] != "" {
+			httpStatus = probeFamilies(families, func(family string) string {
+				ip := clusterIPs[family]
+				if ip == "" {
+					return "ERROR: HTTP Service has no ClusterIP"
+				}
+				clusterIPProbe := defaultHttpProbe()
+				clusterIPProbe.network = familyNetwork("tcp", family)
+				_, span := startTestSpan(ctx, "Probe service", instance, "http", tracing.TargetKey.String(ip))
+				status := httpClient(net.JoinHostPort(ip, "80"), clusterIPProbe, timeouts)
+				endProbeSpan(span, status)
+				return status
+			})
+			httpFail = !strings.Contains(httpStatus, "SUCCESS")
+		}
+		// Connect to the pod on every node directly, to tell which nodes are failing,
+		// these probes always ask the bundled http server
+		dsct := instance.Status.TestResults["http"].DaemonSetCreationTime
+		podIPs := podFamilyIPs(ctx, r, name, found.Namespace, families)
+		nodes, failedNodes := probePods(ctx, instance, r, "http", name, found.Namespace, dsct, reqLogger, func(pod corev1.Pod) string {
+			return probeFamilies(families, func(family string) string {
+				ip := familyPodIP(pod, podIPs, family)
+				if ip == "" {
+					return "ERROR: HTTP pod has no IP"
+				}
+				return httpClient(net.JoinHostPort(ip, "8080"), defaultHttpProbe(), timeouts)
+			})
+		})
+		if !httpFail && len(failedNodes) > 0 {
+			httpFail = true
+			httpStatus = fmt.Sprintf("ERROR: HTTP Failed on nodes: %s", failedNodes)
+		}
 		if httpFail {
-			TestStatus.Status = "Failed"
-			err = updateCoastieStatus(instance, TestStatus, "http", reqLogger, r)
-			if err != nil {
-				return err, retry
-			}
-			message := fmt.Sprintf("Coastie Operator: HTTP Test failed. %s", httpStatus)
-			// Alarm slack if failed
-			err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
-			if err != nil {
-				reqLogger.Error(err, "Failed to send slack message")
-			}
-			// Requeue
-			retry = true
-			return nil, retry
+			return failTest(instance, r, reqLogger, "http", TestStatus, httpStatus, nodes)
+		}
+		err, retry = passTest(ctx, instance, r, reqLogger, "http", TestStatus, httpStatus, probeLatencies(probeLatency, nodes), nodes)
+		if err != nil {
+			return err, retry
 		}
 	} else {
+		span.End()
+		ctx, span = startTestSpan(testCtx, "DaemonSet wait", instance, "http")
+		polls, interval := rolloutPolls(timeouts.rollout)
 		i := 0
-		for i < 5 {
-			// Wait 60 seconds
-			time.Sleep(60 * time.Second)
+		for i < polls {
+			// Wait before checking the DaemonSet again
+			time.Sleep(interval)
 			found := &appsv1.DaemonSet{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+			err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
 			if err != nil {
 				message := fmt.Sprintf("Coastie Operator: Failed to get DaemonSet status")
 				// Alarm slack if failed
-				err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
+				err := notify(ctx, instance, r, "http", message)
 				if err != nil {
 					reqLogger.Error(err, "Failed to send slack message")
 					return err, retry
@@ -156,33 +204,24 @@ func runHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger l
 			}
 			if found.Status.DesiredNumberScheduled == found.Status.NumberReady {
 				reqLogger.Info("DaemonSet is ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
-				i = 10
+				i = polls + 1
 			} else {
 				reqLogger.Info("DaemonSet is not ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
 				i++
 			}
 		}
-		if i == 5 {
-			// If here, means Daemonset to not become ready within 5 minutes
+		if i == polls {
+			// If here, means Daemonset to not become ready within the rollout timeout
 
 			nodes := getNodesWithoutPods(r, name, instance.Namespace)
-			message := fmt.Sprintf("Coastie Operator: DaemonSet took longer than 5 minutes to become ready, nodes with issues: %s", nodes)
-			// Alarm slack if failed
-			err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
-			if err != nil {
-				reqLogger.Error(err, "Failed to send slack message")
-				return err, retry
-			}
-			retry = true
-			return nil, retry
+			Status := fmt.Sprintf("ERROR: DaemonSet took longer than %s to become ready, nodes with issues: %s", timeouts.rollout, nodes)
+			return failTest(instance, r, reqLogger, "http", TestStatus, Status, missingNodeResults(nodes))
 		} else {
 			retry = true
 			return nil, retry
 		}
 	}
 
-	dsct := instance.Status.TestResults["http"].DaemonSetCreationTime
-	getPodsReadyTime(r, name, found.Namespace, reqLogger, dsct)
 	reqLogger.Info("Reached end of HTTPTest", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
 	return nil, retry
 }
@@ -285,45 +324,55 @@ func httpServerIngress(cr *k8sv1alpha1.Coastie, name string) *extensionsv1beta1.
 	}
 }
 
-func httpClient(hostURL string) (status string) {
-	url := fmt.Sprintf("http://%s/ruok", hostURL)
-	resp, err := http.Get(url)
+func httpClient(hostURL string, probe httpProbe, timeouts timeouts) (status string) {
+	req, err := probe.request(hostURL)
+	if err != nil {
+		status = fmt.Sprintf("ERROR: HTTP Failed - Request: %s", err)
+		return
+	}
+	url := req.URL.String()
+	transport := probe.transport(timeouts)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: probe.checkRedirect,
+		Timeout:       timeouts.dial + timeouts.read,
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		status = fmt.Sprintf("ERROR: HTTP Failed - Server: %s", err)
 		return
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
+	problem := probe.check(resp)
+	if problem == "" {
 		status = "SUCCESS: HTTP is working"
 		return
 	} else {
-		status = fmt.Sprintf("ERROR: HTTP Failed - StatusCode Returned was : %d, URL was %s", resp.StatusCode, url)
+		status = fmt.Sprintf("ERROR: HTTP Failed - %s, URL was %s", problem, url)
 		return
 	}
-	status = "ERROR: Should never reach this"
-	return
 }
 
-func deleteHttpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
-	err = nil
+func deleteHttpTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
 	name := fmt.Sprintf("%s-http", instance.Name)
 	// Delete DaemonSet
 	httpDaemonSet := httpServer(instance, name)
-	err = r.client.Delete(context.TODO(), httpDaemonSet)
-	if err != nil {
+	err = r.client.Delete(ctx, httpDaemonSet)
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 	// Delete Service
 	httpService := httpServerService(instance, name)
-	err = r.client.Delete(context.TODO(), httpService)
-	if err != nil {
+	err = r.client.Delete(ctx, httpService)
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 	// Delete Ingress
 	httpIngress := httpServerIngress(instance, name)
-	err = r.client.Delete(context.TODO(), httpIngress)
-	if err != nil {
+	err = r.client.Delete(ctx, httpIngress)
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	return
+	return nil
 }
@@ -0,0 +1,199 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IP families a test can run over
+const (
+	ipv4 = "IPv4"
+	ipv6 = "IPv6"
+)
+
+// testIPFamilies returns the IP families a test probes, a single empty family when the test does not set them,
+// which probes whatever family the cluster hands out
+func testIPFamilies(instance *k8sv1alpha1.Coastie, testName string) (families []string, err error) {
+	settings := testSettings(instance, testName)
+	if len(settings.IPFamilies) == 0 {
+		return []string{""}, nil
+	}
+	if testName != "tcp" && testName != "udp" && testName != "http" {
+		return nil, fmt.Errorf("invalid %s settings: ipfamilies only applies to the tcp, udp and http tests", testName)
+	}
+	seen := make(map[string]bool)
+	for _, family := range settings.IPFamilies {
+		if family != ipv4 && family != ipv6 {
+			return nil, fmt.Errorf("invalid %s settings: ipfamily %s must be %s or %s", testName, family, ipv4, ipv6)
+		}
+		if seen[family] {
+			return nil, fmt.Errorf("invalid %s settings: ipfamily %s is set twice", testName, family)
+		}
+		seen[family] = true
+	}
+	return settings.IPFamilies, nil
+}
+
+// ipFamily returns the family of ip
+func ipFamily(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if parsed.To4() != nil {
+		return ipv4
+	}
+	return ipv6
+}
+
+// familyNetwork restricts network, tcp or udp, to family
+func familyNetwork(network, family string) string {
+	switch family {
+	case ipv4:
+		return network + "4"
+	case ipv6:
+		return network + "6"
+	}
+	return network
+}
+
+// probeFamilies runs probe for every family in turn, and returns the status of the first one failing, which
+// names its family, or the status of the last one
+func probeFamilies(families []string, probe func(family string) (status string)) (status string) {
+	for _, family := range families {
+		status = probe(family)
+		if !strings.Contains(status, "SUCCESS") {
+			if family != "" {
+				status = strings.Replace(status, "ERROR: ", fmt.Sprintf("ERROR: %s ", family), 1)
+			}
+			return status
+		}
+	}
+	if len(families) > 1 {
+		status = fmt.Sprintf("%s over %s", status, strings.Join(families, " and "))
+	}
+	return status
+}
+
+// getOrCreateService creates service unless it exists, and returns its ClusterIP by family once it does. A
+// service with families is read and written as unstructured, as ipFamilies and clusterIPs are newer than the
+// Service type we build against
+func getOrCreateService(ctx context.Context, r *ReconcileCoastie, reqLogger logr.Logger, service *corev1.Service, families []string) (clusterIPs map[string]string, created bool, err error) {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+	if len(families) == 0 || families[0] == "" {
+		err = r.client.Get(ctx, key, service)
+		if err != nil && errors.IsNotFound(err) {
+			reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+			return nil, true, r.client.Create(ctx, service)
+		} else if err != nil {
+			return nil, false, err
+		}
+		return map[string]string{"": service.Spec.ClusterIP}, false, nil
+	}
+
+	found := &unstructured.Unstructured{}
+	found.SetAPIVersion("v1")
+	found.SetKind("Service")
+	err = r.client.Get(ctx, key, found)
+	if err != nil && errors.IsNotFound(err) {
+		u, err := familyService(service, families)
+		if err != nil {
+			return nil, false, err
+		}
+		reqLogger.Info("Creating a new Service", "Service.Namespace", service.Namespace, "Service.Name", service.Name, "IPFamilies", families)
+		return nil, true, r.client.Create(ctx, u)
+	} else if err != nil {
+		return nil, false, err
+	}
+	clusterIPs = make(map[string]string)
+	ips, _, _ := unstructured.NestedStringSlice(found.Object, "spec", "clusterIPs")
+	if len(ips) == 0 {
+		// Clusters without dual-stack only set clusterIP
+		ip, _, _ := unstructured.NestedString(found.Object, "spec", "clusterIP")
+		ips = []string{ip}
+	}
+	for _, ip := range ips {
+		clusterIPs[ipFamily(ip)] = ip
+	}
+	return clusterIPs, false, nil
+}
+
+// familyService returns service as unstructured, asking for a ClusterIP of every family
+func familyService(service *corev1.Service, families []string) (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(service)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetAPIVersion("v1")
+	u.SetKind("Service")
+	policy := "SingleStack"
+	if len(families) > 1 {
+		policy = "RequireDualStack"
+	}
+	if err := unstructured.SetNestedStringSlice(u.Object, families, "spec", "ipFamilies"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(u.Object, policy, "spec", "ipFamilyPolicy"); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// podFamilyIPs returns the IPs of every test pod by pod name, read as unstructured as podIPs is newer than the
+// Pod type we build against. Only the pods of tests with families need them
+func podFamilyIPs(ctx context.Context, r *ReconcileCoastie, name, namespace string, families []string) map[string][]string {
+	podIPs := make(map[string][]string)
+	if len(families) == 0 || families[0] == "" {
+		return podIPs
+	}
+	opts := &client.ListOptions{}
+	opts.SetLabelSelector(fmt.Sprintf("app=%s", name))
+	opts.InNamespace(namespace)
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("v1")
+	list.SetKind("PodList")
+	if err := r.client.List(ctx, opts, list); err != nil {
+		return podIPs
+	}
+	for _, pod := range list.Items {
+		ips, _, _ := unstructured.NestedSlice(pod.Object, "status", "podIPs")
+		for _, v := range ips {
+			if ip, ok := v.(map[string]interface{}); ok {
+				if s, ok := ip["ip"].(string); ok {
+					podIPs[pod.GetName()] = append(podIPs[pod.GetName()], s)
+				}
+			}
+		}
+	}
+	return podIPs
+}
+
+// familyPodIP returns the IP of pod in family, its primary IP when family is empty
+func familyPodIP(pod corev1.Pod, podIPs map[string][]string, family string) string {
+	if family == "" {
+		return pod.Status.PodIP
+	}
+	ips := podIPs[pod.Name]
+	if len(ips) == 0 {
+		// Clusters without dual-stack only set podIP
+		ips = []string{pod.Status.PodIP}
+	}
+	for _, ip := range ips {
+		if ipFamily(ip) == family {
+			return ip
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Percentile of the probe latencies used when the test does not set one
+const defaultLatencyPercentile = 95
+
+// latencyThresholds of a test, a zero threshold is disabled
+type latencyThresholds struct {
+	percentile int
+	warn       time.Duration
+	fail       time.Duration
+}
+
+// testLatencyThresholds returns the latency thresholds of a test, with the defaults applied
+func testLatencyThresholds(instance *k8sv1alpha1.Coastie, testName string) (thresholds latencyThresholds, err error) {
+	thresholds.percentile = defaultLatencyPercentile
+	settings := testSettings(instance, testName).Latency
+	if settings == nil {
+		return thresholds, nil
+	}
+	if !latencyTest(testName) {
+		return thresholds, fmt.Errorf("invalid %s settings: latency only applies to the tests measuring a round trip time", testName)
+	}
+	if settings.Percentile != 0 {
+		if settings.Percentile < 1 || settings.Percentile > 100 {
+			return thresholds, fmt.Errorf("invalid %s settings: latency percentile %d must be between 1 and 100", testName, settings.Percentile)
+		}
+		thresholds.percentile = settings.Percentile
+	}
+	durationOrDefault(&thresholds.warn, settings.Warn)
+	durationOrDefault(&thresholds.fail, settings.Fail)
+	if thresholds.warn > 0 && thresholds.fail > 0 && thresholds.warn > thresholds.fail {
+		return thresholds, fmt.Errorf("invalid %s settings: latency warn %s can not exceed fail %s", testName, thresholds.warn, thresholds.fail)
+	}
+	return thresholds, nil
+}
+
+// latencyTest returns true for the tests whose probes record a round trip time, the only ones latency thresholds
+// apply to
+func latencyTest(testName string) bool {
+	switch testName {
+	case "tcp", "udp", "http", "grpc", "egress":
+		return true
+	}
+	return false
+}
+
+// percentileLatency returns the nearest rank percentile of latencies
+func percentileLatency(latencies []time.Duration, percentile int) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(float64(percentile) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// probeLatencies returns the latency of the probe through the Service or Ingress along with the latency of
+// the probe of every node
+func probeLatencies(probeLatency time.Duration, nodes []k8sv1alpha1.NodeResult) (latencies []time.Duration) {
+	latencies = append(latencies, probeLatency)
+	for _, node := range nodes {
+		if node.Latency != nil {
+			latencies = append(latencies, node.Latency.Duration)
+		}
+	}
+	return latencies
+}
+
+// passTest completes a test whose probes passed with status, degrading or failing it when the percentile of the
+// probe latencies exceeds the thresholds of the test
+func passTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, TestStatus k8sv1alpha1.TestResult, status string, latencies []time.Duration, nodes []k8sv1alpha1.NodeResult) (err error, retry bool) {
+	thresholds, err := testLatencyThresholds(instance, testName)
+	if err != nil {
+		return err, retry
+	}
+	latency := percentileLatency(latencies, thresholds.percentile)
+	TestStatus.Latency = &metav1.Duration{Duration: latency}
+	// The status is Running by now, the Degraded condition still holds the previous result
+	wasDegraded := testCondition(TestStatus, "Degraded") == "True"
+	Status := status
+	switch {
+	case thresholds.fail > 0 && latency > thresholds.fail:
+		TestStatus.Status = "Failed"
+		Status = fmt.Sprintf("ERROR: %s p%d latency %s exceeds %s", strings.ToUpper(testName), thresholds.percentile, latency, thresholds.fail)
+	case thresholds.warn > 0 && latency > thresholds.warn:
+		TestStatus.Status = "Degraded"
+		Status = fmt.Sprintf("WARNING: %s p%d latency %s exceeds %s", strings.ToUpper(testName), thresholds.percentile, latency, thresholds.warn)
+	default:
+		TestStatus.Status = "Passed"
+	}
+	reqLogger.Info("Probe latency", "TestName", strings.ToUpper(testName), "Percentile", thresholds.percentile, "Latency", latency, "Status", TestStatus.Status)
+	setLatencyMetric(instance, testName, latency)
+	if TestStatus.Status == "Failed" {
+		return failTest(instance, r, reqLogger, testName, TestStatus, Status, nodes)
+	}
+	err = completeTest(ctx, instance, r, reqLogger, testName, TestStatus, Status, nodes)
+	if err != nil {
+		return err, retry
+	}
+
+	// Warn once when the test becomes degraded, rather than on every run
+	if TestStatus.Status == "Degraded" && !wasDegraded {
+		message := fmt.Sprintf("Coastie Operator: %s Test degraded. %s", strings.ToUpper(testName), Status)
+		err := notify(ctx, instance, r, testName, message)
+		if err != nil {
+			reqLogger.Error(err, "Failed to send slack message")
+		}
+	}
+	return nil, retry
+}
+
+// setTestConditions sets the Available and Degraded conditions of a test from its status
+func setTestConditions(TestStatus *k8sv1alpha1.TestResult, message string) {
+	setAvailableCondition(TestStatus, message)
+	degraded := "False"
+	degradedReason := ""
+	degradedMessage := ""
+	if TestStatus.Status == "Degraded" {
+		degraded = "True"
+		degradedReason = "LatencyAboveWarnThreshold"
+		degradedMessage = message
+	}
+	setTestCondition(TestStatus, k8sv1alpha1.TestCondition{
+		Type:    "Degraded",
+		Status:  degraded,
+		Reason:  degradedReason,
+		Message: degradedMessage,
+	})
+}
+
+// setAvailableCondition sets the Available condition of a test from its status
+func setAvailableCondition(TestStatus *k8sv1alpha1.TestResult, message string) {
+	available := "True"
+	if TestStatus.Status == "Failed" {
+		available = "False"
+	}
+	setTestCondition(TestStatus, k8sv1alpha1.TestCondition{
+		Type:    "Available",
+		Status:  available,
+		Reason:  TestStatus.Status,
+		Message: message,
+	})
+}
+
+// testCondition returns the status of a condition of a test, empty when the test does not have it
+func testCondition(TestStatus k8sv1alpha1.TestResult, conditionType string) string {
+	for _, condition := range TestStatus.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return ""
+}
+
+// setTestCondition adds or updates a condition, keeping its transition time unless its status changed
+func setTestCondition(TestStatus *k8sv1alpha1.TestResult, condition k8sv1alpha1.TestCondition) {
+	condition.LastTransitionTime = metav1.Now()
+	for i, existing := range TestStatus.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		TestStatus.Conditions[i] = condition
+		return
+	}
+	TestStatus.Conditions = append(TestStatus.Conditions, condition)
+}
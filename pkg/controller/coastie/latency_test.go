@@ -0,0 +1,130 @@
+package coastie
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPercentileLatency(t *testing.T) {
+	ms := func(values ...int) (latencies []time.Duration) {
+		for _, v := range values {
+			latencies = append(latencies, time.Duration(v)*time.Millisecond)
+		}
+		return latencies
+	}
+
+	tests := []struct {
+		name       string
+		latencies  []time.Duration
+		percentile int
+		latency    time.Duration
+	}{
+		{name: "no samples", percentile: 95},
+		{name: "single sample p1", latencies: ms(7), percentile: 1, latency: 7 * time.Millisecond},
+		{name: "single sample p100", latencies: ms(7), percentile: 100, latency: 7 * time.Millisecond},
+		{name: "p1 is the smallest", latencies: ms(30, 10, 20), percentile: 1, latency: 10 * time.Millisecond},
+		{name: "p100 is the largest", latencies: ms(30, 10, 20), percentile: 100, latency: 30 * time.Millisecond},
+		{name: "p50 of an even count", latencies: ms(40, 10, 30, 20), percentile: 50, latency: 20 * time.Millisecond},
+		{name: "p95 of ten samples", latencies: ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 100), percentile: 95, latency: 100 * time.Millisecond},
+		{name: "p90 of ten samples", latencies: ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 100), percentile: 90, latency: 9 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentileLatency(tt.latencies, tt.percentile); got != tt.latency {
+				t.Errorf("p%d of %v: expected %s, got %s", tt.percentile, tt.latencies, tt.latency, got)
+			}
+		})
+	}
+}
+
+func TestTestLatencyThresholds(t *testing.T) {
+	duration := func(d time.Duration) *metav1.Duration {
+		return &metav1.Duration{Duration: d}
+	}
+
+	tests := []struct {
+		name       string
+		testName   string
+		latency    *k8sv1alpha1.LatencyThresholds
+		thresholds latencyThresholds
+		err        string
+	}{
+		{
+			name:       "defaults",
+			testName:   "tcp",
+			thresholds: latencyThresholds{percentile: defaultLatencyPercentile},
+		},
+		{
+			name:       "percentile 1",
+			testName:   "http",
+			latency:    &k8sv1alpha1.LatencyThresholds{Percentile: 1, Warn: duration(time.Second)},
+			thresholds: latencyThresholds{percentile: 1, warn: time.Second},
+		},
+		{
+			name:       "percentile 100",
+			testName:   "grpc",
+			latency:    &k8sv1alpha1.LatencyThresholds{Percentile: 100, Fail: duration(time.Second)},
+			thresholds: latencyThresholds{percentile: 100, fail: time.Second},
+		},
+		{
+			name:     "percentile 101",
+			testName: "udp",
+			latency:  &k8sv1alpha1.LatencyThresholds{Percentile: 101},
+			err:      "latency percentile 101 must be between 1 and 100",
+		},
+		{
+			name:     "negative percentile",
+			testName: "udp",
+			latency:  &k8sv1alpha1.LatencyThresholds{Percentile: -1},
+			err:      "latency percentile -1 must be between 1 and 100",
+		},
+		{
+			name:       "warn equal to fail",
+			testName:   "egress",
+			latency:    &k8sv1alpha1.LatencyThresholds{Warn: duration(time.Second), Fail: duration(time.Second)},
+			thresholds: latencyThresholds{percentile: defaultLatencyPercentile, warn: time.Second, fail: time.Second},
+		},
+		{
+			name:     "warn above fail",
+			testName: "tcp",
+			latency:  &k8sv1alpha1.LatencyThresholds{Warn: duration(2 * time.Second), Fail: duration(time.Second)},
+			err:      "latency warn 2s can not exceed fail 1s",
+		},
+		{
+			name:     "test without round trip time",
+			testName: "bandwidth",
+			latency:  &k8sv1alpha1.LatencyThresholds{Warn: duration(time.Second)},
+			err:      "invalid bandwidth settings: latency only applies to the tests measuring a round trip time",
+		},
+		{
+			name:       "test without round trip time nor settings",
+			testName:   "mtu",
+			thresholds: latencyThresholds{percentile: defaultLatencyPercentile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &k8sv1alpha1.Coastie{}
+			instance.Spec.TestSettings = map[string]k8sv1alpha1.TestSettings{
+				tt.testName: {Latency: tt.latency},
+			}
+			thresholds, err := testLatencyThresholds(instance, tt.testName)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if thresholds != tt.thresholds {
+				t.Errorf("expected %+v, got %+v", tt.thresholds, thresholds)
+			}
+		})
+	}
+}
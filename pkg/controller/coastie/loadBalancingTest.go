@@ -0,0 +1,252 @@
+package coastie
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	instr "k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Requests sent through the ClusterIP, and through the Ingress, when the test does not set them
+const (
+	defaultLoadBalancingRequestsPerEndpoint = 10
+	minLoadBalancingRequests                = 100
+)
+
+// loadBalancingPath is the path of the agent answering which pod it runs in
+const loadBalancingPath = "/whoami"
+
+func runLoadBalancingTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	requests := 0
+	if settings := testSettings(instance, "loadbalancing").LoadBalancing; settings != nil {
+		if settings.Requests < 0 {
+			return fmt.Errorf("invalid loadbalancing settings: requests %d can not be negative", settings.Requests), retry
+		}
+		requests = settings.Requests
+	}
+	name := fmt.Sprintf("%s-loadbalancing", instance.Name)
+	timeouts := testTimeouts(instance, r, "loadbalancing")
+	// The Service and Ingress are created along with the agents
+	objects := []runtime.Object{whoamiService(instance, name)}
+	if instance.Spec.HostURL != "" {
+		objects = append(objects, loadBalancingIngress(instance, name))
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "loadbalancing", objects, func(ctx context.Context, _ *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		status, nodes := loadBalancingProbe(ctx, instance, r, reqLogger, name, requests, timeouts, pods)
+		return status, nil, nodes
+	})
+}
+
+// createIfNotFound creates object, owned by the Coastie, unless it exists
+func createIfNotFound(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, object runtime.Object) error {
+	meta := object.(metav1.Object)
+	if err := controllerutil.SetControllerReference(instance, meta, r.scheme); err != nil {
+		return err
+	}
+	name := meta.GetName()
+	found := object.DeepCopyObject()
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new object", "Kind", fmt.Sprintf("%T", object), "Namespace", instance.Namespace, "Name", name)
+		return r.client.Create(ctx, object)
+	}
+	return err
+}
+
+// loadBalancingProbe sends requests through the ClusterIP, and the Ingress, of the agents and counts the requests
+// every agent answered. Endpoints answering no request fail the test
+func loadBalancingProbe(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, name string, requests int, timeouts timeouts, pods []corev1.Pod) (status string, nodes []k8sv1alpha1.NodeResult) {
+	if requests == 0 {
+		requests = defaultLoadBalancingRequestsPerEndpoint * len(pods)
+		if requests < minLoadBalancingRequests {
+			requests = minLoadBalancingRequests
+		}
+	}
+	service := &corev1.Service{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, service)
+	if err != nil {
+		return fmt.Sprintf("ERROR: LOADBALANCING Service not found: %s", err), nil
+	}
+	// Every path is named after the metric holding the requests every endpoint answered through it
+	paths := [][2]string{{"clusterip", net.JoinHostPort(service.Spec.ClusterIP, "80")}}
+	if instance.Spec.HostURL != "" {
+		paths = append(paths, [2]string{"ingress", instance.Spec.HostURL})
+	}
+
+	for _, pod := range pods {
+		nodes = append(nodes, k8sv1alpha1.NodeResult{
+			NodeName: pod.Spec.NodeName,
+			PodName:  pod.Name,
+			Status:   "Passed",
+			Metrics:  map[string]string{},
+		})
+	}
+	var problems []string
+	for _, path := range paths {
+		metric, target := path[0], path[1]
+		_, span := startTestSpan(ctx, "Probe path", instance, "loadbalancing", tracing.TargetKey.String(target))
+		counts, failed, err := distribution(target, requests, timeouts)
+		pathStatus := "SUCCESS"
+		if failed > 0 {
+			pathStatus = fmt.Sprintf("ERROR: %d of %d requests through the %s failed: %s", failed, requests, metric, err)
+		}
+		var idle []string
+		for i := range nodes {
+			node := &nodes[i]
+			node.Metrics[metric] = strconv.Itoa(counts[node.PodName])
+			if counts[node.PodName] == 0 {
+				idle = append(idle, node.NodeName)
+				node.Status = "Failed"
+				if node.Message != "" {
+					node.Message += ", "
+				}
+				node.Message += fmt.Sprintf("no request through the %s", metric)
+			}
+		}
+		if len(idle) > 0 && failed == 0 {
+			pathStatus = fmt.Sprintf("ERROR: endpoints received no request through the %s: %s", metric, idle)
+		}
+		endProbeSpan(span, pathStatus)
+		reqLogger.Info("Load balancing", "Path", metric, "Target", target, "Requests", requests, "Failed", failed, "Distribution", counts)
+		if pathStatus != "SUCCESS" {
+			problems = append(problems, strings.TrimPrefix(pathStatus, "ERROR: "))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Sprintf("ERROR: LOADBALANCING Failed, %s", strings.Join(problems, ", ")), nodes
+	}
+	return fmt.Sprintf("SUCCESS: LOADBALANCING spread %d requests per path across %d endpoints", requests, len(nodes)), nodes
+}
+
+// distribution sends requests to the whoami of the agents behind target, on a new connection every time so
+// every request is balanced, and returns the requests answered by every pod. A first request is retried until
+// target answers, as a new Ingress takes a while to route
+func distribution(target string, requests int, timeouts timeouts) (counts map[string]int, failed int, err error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       (&net.Dialer{Timeout: timeouts.dial}).DialContext,
+			DisableKeepAlives: true,
+		},
+		Timeout: timeouts.dial + timeouts.read,
+	}
+	url := fmt.Sprintf("http://%s%s", target, loadBalancingPath)
+	for i := 0; i < timeouts.probeAttempts; i++ {
+		if _, err = whoami(client, url); err == nil {
+			break
+		}
+		time.Sleep(timeouts.probeInterval)
+	}
+
+	counts = make(map[string]int)
+	var lastErr error
+	for i := 0; i < requests; i++ {
+		who, err := whoami(client, url)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		counts[who.Pod]++
+	}
+	return counts, failed, lastErr
+}
+
+// whoami asks the agent behind url which pod it runs in
+func whoami(client *http.Client, url string) (who agent.WhoAmI, err error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return who, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return who, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&who)
+	return who, err
+}
+
+// whoamiService returns the Service in front of the whoami of the agents
+func whoamiService(cr *k8sv1alpha1.Coastie, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "whoami",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: instr.FromInt(agent.PublicPort),
+				},
+			},
+			Selector: map[string]string{
+				"app": name,
+			},
+		},
+	}
+}
+
+// loadBalancingIngress returns the Ingress routing the whoami path of the hosturl to the agents
+func loadBalancingIngress(cr *k8sv1alpha1.Coastie, name string) *extensionsv1beta1.Ingress {
+	return &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: cr.Spec.HostURL,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: loadBalancingPath,
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: name,
+										ServicePort: instr.FromInt(80),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func deleteLoadBalancingTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	name := fmt.Sprintf("%s-loadbalancing", instance.Name)
+	err = deleteAgentTest(ctx, instance, r, reqLogger, "loadbalancing")
+	if err != nil {
+		return err
+	}
+	// Delete Service and Ingress
+	for _, object := range []runtime.Object{whoamiService(instance, name), loadBalancingIngress(instance, name)} {
+		err = r.client.Delete(ctx, object)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package coastie
+
+import (
+	"strings"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	availabilityRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coastie_test_availability_ratio",
+		Help: "Ratio of passed test runs over a rolling window",
+	}, []string{"namespace", "coastie", "test", "window"})
+
+	errorBudgetBurnRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coastie_test_error_budget_burn_rate",
+		Help: "Rate the error budget of a test is consumed over a rolling window, 1 consumes exactly the budget",
+	}, []string{"namespace", "coastie", "test", "window"})
+
+	probeLatencySeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coastie_test_probe_latency_seconds",
+		Help: "Percentile round trip time of the probes of the latest passed test run",
+	}, []string{"namespace", "coastie", "test"})
+
+	loadBalancerAllocationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "coastie_test_loadbalancer_allocation_seconds",
+		Help: "Time the LoadBalancer Service of the latest test run took to get an external IP",
+	}, []string{"namespace", "coastie", "test"})
+)
+
+func init() {
+	// Register the metrics with the registry served on the metrics port of the manager
+	metrics.Registry.MustRegister(availabilityRatio, errorBudgetBurnRate, probeLatencySeconds, loadBalancerAllocationSeconds)
+}
+
+func setAvailabilityMetrics(instance *k8sv1alpha1.Coastie, testName string, window time.Duration, ratio, burnRate float64) {
+	labels := prometheus.Labels{
+		"namespace": instance.Namespace,
+		"coastie":   instance.Name,
+		"test":      strings.ToLower(testName),
+		"window":    window.String(),
+	}
+	availabilityRatio.With(labels).Set(ratio)
+	errorBudgetBurnRate.With(labels).Set(burnRate)
+}
+
+func setLatencyMetric(instance *k8sv1alpha1.Coastie, testName string, latency time.Duration) {
+	probeLatencySeconds.With(prometheus.Labels{
+		"namespace": instance.Namespace,
+		"coastie":   instance.Name,
+		"test":      strings.ToLower(testName),
+	}).Set(latency.Seconds())
+}
+
+func setLoadBalancerMetric(instance *k8sv1alpha1.Coastie, testName string, allocation time.Duration) {
+	loadBalancerAllocationSeconds.With(prometheus.Labels{
+		"namespace": instance.Namespace,
+		"coastie":   instance.Name,
+		"test":      strings.ToLower(testName),
+	}).Set(allocation.Seconds())
+}
@@ -0,0 +1,124 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Settings of the mtu test used when the test does not set them
+const (
+	defaultMTUMode    = "sampled"
+	defaultMTUTimeout = 1 * time.Second
+)
+
+// mtuSettings of the mtu test, with the defaults applied. An expected MTU of 0 expects the MTU of the pod
+type mtuSettings struct {
+	expected int
+	mode     string
+	timeout  time.Duration
+}
+
+// testMTUSettings returns the settings of the mtu test
+func testMTUSettings(instance *k8sv1alpha1.Coastie) (settings mtuSettings, err error) {
+	settings = mtuSettings{
+		mode:    defaultMTUMode,
+		timeout: defaultMTUTimeout,
+	}
+	mtu := testSettings(instance, "mtu").MTU
+	if mtu == nil {
+		return settings, nil
+	}
+	if mtu.ExpectedMTU != 0 {
+		if mtu.ExpectedMTU < agent.MinMTU || mtu.ExpectedMTU > agent.MaxMTU {
+			return settings, fmt.Errorf("invalid mtu settings: expectedmtu %d must be between %d and %d", mtu.ExpectedMTU, agent.MinMTU, agent.MaxMTU)
+		}
+		settings.expected = mtu.ExpectedMTU
+	}
+	switch mtu.Mode {
+	case "":
+	case "sampled", "fullmesh":
+		settings.mode = mtu.Mode
+	default:
+		return settings, fmt.Errorf("invalid mtu settings: mode %s must be sampled or fullmesh", mtu.Mode)
+	}
+	durationOrDefault(&settings.timeout, mtu.Timeout)
+	if settings.timeout > agent.MaxMTUTimeout {
+		return settings, fmt.Errorf("invalid mtu settings: timeout %s must be at most %s", settings.timeout, agent.MaxMTUTimeout)
+	}
+	return settings, nil
+}
+
+func runMTUTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	settings, err := testMTUSettings(instance)
+	if err != nil {
+		return err, retry
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "mtu", nil, func(ctx context.Context, c *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		status, nodes := mtuProbe(ctx, c, instance, reqLogger, settings, pods)
+		return status, nil, nodes
+	})
+}
+
+// mtuProbe has the agent of every pair of nodes search the largest packets echoed by the other agent
+func mtuProbe(ctx context.Context, c *agent.Client, instance *k8sv1alpha1.Coastie, reqLogger logr.Logger, settings mtuSettings, pods []corev1.Pod) (status string, nodes []k8sv1alpha1.NodeResult) {
+	pairs := nodePairs(pods, settings.mode)
+	if len(pairs) == 0 {
+		return "SUCCESS: MTU needs at least 2 nodes, no packet was sent", nil
+	}
+	var failed []string
+	for _, pair := range pairs {
+		from, to := pair[0], pair[1]
+		node := k8sv1alpha1.NodeResult{
+			NodeName: from.Spec.NodeName,
+			PodName:  from.Name,
+			Target:   to.Spec.NodeName,
+		}
+		_, span := startTestSpan(ctx, "Probe pair", instance, "mtu", tracing.NodeKey.String(from.Spec.NodeName), tracing.TargetKey.String(to.Spec.NodeName))
+		result, err := c.MTU(ctx, from.Status.PodIP, to.Status.PodIP, settings.expected, settings.timeout)
+		pairStatus := "SUCCESS"
+		if err != nil {
+			pairStatus = fmt.Sprintf("ERROR: %s", err)
+		} else {
+			node.Metrics = map[string]string{
+				"podmtu":   fmt.Sprintf("%d", result.PodMTU),
+				"pathmtu":  fmt.Sprintf("%d", result.PathMTU),
+				"tcpbytes": fmt.Sprintf("%d", result.TCPBytes),
+			}
+			expected := settings.expected
+			if expected == 0 {
+				expected = result.PodMTU
+			}
+			if result.PathMTU < expected {
+				pairStatus = fmt.Sprintf("ERROR: path MTU %d is below %d", result.PathMTU, expected)
+			} else if result.TCPBytes < result.MaxTCPBytes {
+				pairStatus = fmt.Sprintf("ERROR: TCP payloads above %d bytes are not echoed", result.TCPBytes)
+			}
+		}
+		endProbeSpan(span, pairStatus)
+		reqLogger.Info("MTU between nodes", "From", from.Spec.NodeName, "To", to.Spec.NodeName, "PathMTU", node.Metrics["pathmtu"], "Status", pairStatus)
+		if pairStatus == "SUCCESS" {
+			node.Status = "Passed"
+		} else {
+			node.Status = "Failed"
+			node.Message = pairStatus
+			failed = append(failed, fmt.Sprintf("%s->%s (%s)", from.Spec.NodeName, to.Spec.NodeName, strings.TrimPrefix(pairStatus, "ERROR: ")))
+		}
+		nodes = append(nodes, node)
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("ERROR: MTU Failed between nodes: %s", strings.Join(failed, ", ")), nodes
+	}
+	return "SUCCESS: MTU is working", nodes
+}
+
+func deleteMTUTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	return deleteAgentTest(ctx, instance, r, reqLogger, "mtu")
+}
@@ -0,0 +1,213 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	instr "k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Timeout of every connection of the networkpolicy test when the test does not set it
+const defaultNetworkPolicyTimeout = 2 * time.Second
+
+func runNetworkPolicyTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	timeout := defaultNetworkPolicyTimeout
+	if settings := testSettings(instance, "networkpolicy").NetworkPolicy; settings != nil {
+		durationOrDefault(&timeout, settings.Timeout)
+	}
+	if timeout > agent.MaxTimeout {
+		return fmt.Errorf("invalid networkpolicy settings: timeout %s must be at most %s", timeout, agent.MaxTimeout), retry
+	}
+	name := fmt.Sprintf("%s-networkpolicy", instance.Name)
+	// The policies come first, so the denied agents never reach the servers
+	objects := []runtime.Object{
+		networkPolicyDenyAll(instance, name),
+		networkPolicyAllow(instance, name),
+		agentServer(instance, fmt.Sprintf("%s-allowed", name), r.config.AgentImage),
+		agentServer(instance, fmt.Sprintf("%s-denied", name), r.config.AgentImage),
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "networkpolicy", objects, func(ctx context.Context, c *agent.Client, servers []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		allowed := listTestPods(r, fmt.Sprintf("%s-allowed", name), instance.Namespace)
+		denied := listTestPods(r, fmt.Sprintf("%s-denied", name), instance.Namespace)
+		status, nodes := networkPolicyProbe(ctx, c, instance, reqLogger, timeout, servers, allowed, denied)
+		return status, nil, nodes
+	})
+}
+
+// networkPolicyProbe has the allowed and denied agent on every node connect to the agent server of another node.
+// The allowed connection must go through, the denied one must be blocked
+func networkPolicyProbe(ctx context.Context, c *agent.Client, instance *k8sv1alpha1.Coastie, reqLogger logr.Logger, timeout time.Duration, servers, allowed, denied []corev1.Pod) (status string, nodes []k8sv1alpha1.NodeResult) {
+	var targets []corev1.Pod
+	for _, pod := range servers {
+		if pod.Status.PodIP != "" {
+			targets = append(targets, pod)
+		}
+	}
+	if len(targets) == 0 {
+		return "ERROR: NETWORKPOLICY no agent server has an IP", nil
+	}
+	// Every client connects to the server next to its node in a shuffled ring, on its own node when alone
+	ring := rand.Perm(len(targets))
+	next := make(map[string]corev1.Pod)
+	for i := range ring {
+		next[targets[ring[i]].Spec.NodeName] = targets[ring[(i+1)%len(ring)]]
+	}
+
+	results := make(map[string]*k8sv1alpha1.NodeResult)
+	var order []string
+	var failed []string
+	for _, client := range [][]corev1.Pod{allowed, denied} {
+		for _, from := range client {
+			access := "denied"
+			if strings.HasSuffix(from.Labels["app"], "-allowed") {
+				access = "allowed"
+			}
+			node, ok := results[from.Spec.NodeName]
+			if !ok {
+				node = &k8sv1alpha1.NodeResult{
+					NodeName: from.Spec.NodeName,
+					Status:   "Passed",
+					Metrics:  map[string]string{},
+				}
+				results[from.Spec.NodeName] = node
+				order = append(order, from.Spec.NodeName)
+			}
+			to, ok := next[from.Spec.NodeName]
+			if !ok {
+				to = targets[rand.Intn(len(targets))]
+			}
+			node.Target = to.Spec.NodeName
+
+			_, span := startTestSpan(ctx, "Probe pair", instance, "networkpolicy", tracing.NodeKey.String(from.Spec.NodeName), tracing.TargetKey.String(to.Spec.NodeName))
+			result, err := c.Connect(ctx, from.Status.PodIP, to.Status.PodIP, timeout)
+			problem := ""
+			switch {
+			case err != nil:
+				problem = fmt.Sprintf("%s agent did not answer: %s", access, err)
+			case access == "allowed" && !result.Connected:
+				node.Metrics[access] = "blocked"
+				problem = fmt.Sprintf("allowed connection was blocked: %s", result.Error)
+			case access == "allowed":
+				node.Metrics[access] = "connected"
+			case result.Connected:
+				node.Metrics[access] = "connected"
+				problem = "denied connection went through"
+			default:
+				node.Metrics[access] = "blocked"
+			}
+			pairStatus := "SUCCESS"
+			if problem != "" {
+				pairStatus = fmt.Sprintf("ERROR: %s", problem)
+			}
+			endProbeSpan(span, pairStatus)
+			reqLogger.Info("NetworkPolicy between nodes", "From", from.Spec.NodeName, "To", to.Spec.NodeName, "Access", access, "Status", pairStatus)
+			if problem != "" {
+				node.Status = "Failed"
+				if node.Message != "" {
+					node.Message += ", "
+				}
+				node.Message += problem
+				failed = append(failed, fmt.Sprintf("%s->%s (%s)", from.Spec.NodeName, to.Spec.NodeName, problem))
+			}
+		}
+	}
+	for _, name := range order {
+		nodes = append(nodes, *results[name])
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("ERROR: NETWORKPOLICY Failed between nodes: %s", strings.Join(failed, ", ")), nodes
+	}
+	return "SUCCESS: NETWORKPOLICY is enforced", nodes
+}
+
+// networkPolicyDenyAll returns the NetworkPolicy denying every connection to the agent servers
+func networkPolicyDenyAll(cr *k8sv1alpha1.Coastie, name string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-deny-all", name),
+			Namespace: cr.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+// networkPolicyAllow returns the NetworkPolicy allowing the allowed agents to connect to the echo of the agent
+// servers
+func networkPolicyAllow(cr *k8sv1alpha1.Coastie, name string) *networkingv1.NetworkPolicy {
+	protocol := corev1.ProtocolTCP
+	port := instr.FromInt(agent.EchoPort)
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-allow", name),
+			Namespace: cr.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{
+							Protocol: &protocol,
+							Port:     &port,
+						},
+					},
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": fmt.Sprintf("%s-allowed", name),
+								},
+							},
+						},
+					},
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func deleteNetworkPolicyTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	name := fmt.Sprintf("%s-networkpolicy", instance.Name)
+	err = deleteAgentTest(ctx, instance, r, reqLogger, "networkpolicy")
+	if err != nil {
+		return err
+	}
+	// Delete the client agents and the policies
+	objects := []runtime.Object{
+		agentServer(instance, fmt.Sprintf("%s-allowed", name), r.config.AgentImage),
+		agentServer(instance, fmt.Sprintf("%s-denied", name), r.config.AgentImage),
+		networkPolicyDenyAll(instance, name),
+		networkPolicyAllow(instance, name),
+	}
+	for _, object := range objects {
+		err = r.client.Delete(ctx, object)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
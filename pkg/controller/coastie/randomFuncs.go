@@ -13,24 +13,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func getPodsReadyTime(r *ReconcileCoastie, name, namespace string, reqLogger logr.Logger, dsct string) {
+func getPodsReadyTime(r *ReconcileCoastie, name, namespace string, reqLogger logr.Logger, dsct string) (startup map[string]time.Duration) {
+	startup = make(map[string]time.Duration)
 	t, err := time.Parse(time.RFC3339, dsct)
 	if err != nil {
-		panic(err.Error())
+		reqLogger.Info("Unable to parse DaemonSet creation time, skipping pod startup times", "DaemonSetCreationTime", dsct, "Namespace", namespace, "Name", name)
+		return
 	}
 
-	opts := &client.ListOptions{}
-	opts.SetLabelSelector(fmt.Sprintf("app=%s", name))
-	opts.InNamespace(namespace)
-
-	podList := &corev1.PodList{}
-	ctx := context.TODO()
-	r.client.List(ctx, opts, podList)
-
-	for _, v := range podList.Items {
+	for _, v := range listTestPods(r, name, namespace) {
 		for _, pv := range v.Status.Conditions {
 			if pv.Type == "Ready" {
 				timeToStart := pv.LastTransitionTime.Sub(t)
+				startup[v.Name] = timeToStart
 				reqLogger.Info("Pod Times", "Pod.Name", v.Name, "Pod.TimeToStartInSeconds", timeToStart, "NodeName", v.Spec.NodeName, "Namespace", namespace, "Name", name)
 			}
 		}
@@ -39,6 +34,18 @@ func getPodsReadyTime(r *ReconcileCoastie, name, namespace string, reqLogger log
 	return
 }
 
+// listTestPods returns the pods of the test DaemonSet name
+func listTestPods(r *ReconcileCoastie, name, namespace string) (pods []corev1.Pod) {
+	opts := &client.ListOptions{}
+	opts.SetLabelSelector(fmt.Sprintf("app=%s", name))
+	opts.InNamespace(namespace)
+
+	podList := &corev1.PodList{}
+	ctx := context.TODO()
+	r.client.List(ctx, opts, podList)
+	return podList.Items
+}
+
 func getNodesWithoutPods(r *ReconcileCoastie, name, namespace string) (nodes []string) {
 	opts := &client.ListOptions{}
 	nodesWithPods := make(map[string]string)
@@ -72,3 +79,17 @@ func getNodesWithoutPods(r *ReconcileCoastie, name, namespace string) (nodes []s
 	}
 	return
 }
+
+// Time waited between two checks of a DaemonSet which is not ready yet
+const rolloutPollInterval = 60 * time.Second
+
+// rolloutPolls returns how many times a DaemonSet which is not ready is checked before the rollout timeout,
+// and the time waited before every check
+func rolloutPolls(timeout time.Duration) (polls int, interval time.Duration) {
+	interval = rolloutPollInterval
+	if timeout < interval {
+		interval = timeout
+	}
+	polls = int((timeout + interval - 1) / interval)
+	return polls, interval
+}
@@ -0,0 +1,117 @@
+package coastie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/health"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Retry policy used when the test does not set one
+const (
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = 5 * time.Second
+	defaultMaxBackoff     = 1 * time.Minute
+	defaultRetryDeadline  = 30 * time.Minute
+)
+
+// testSettings returns the settings of a test, empty when the Coastie does not set any
+func testSettings(instance *k8sv1alpha1.Coastie, testName string) k8sv1alpha1.TestSettings {
+	return instance.Spec.TestSettings[testName]
+}
+
+// retryPolicy returns the retry policy of a test, with the defaults applied
+func retryPolicy(instance *k8sv1alpha1.Coastie, testName string) (maxAttempts int, initialBackoff, maxBackoff, deadline time.Duration) {
+	maxAttempts = defaultMaxAttempts
+	initialBackoff = defaultInitialBackoff
+	maxBackoff = defaultMaxBackoff
+	deadline = defaultRetryDeadline
+	policy := testSettings(instance, testName).Retry
+	if policy == nil {
+		return
+	}
+	if policy.MaxAttempts > 0 {
+		maxAttempts = policy.MaxAttempts
+	}
+	if policy.InitialBackoff != nil && policy.InitialBackoff.Duration > 0 {
+		initialBackoff = policy.InitialBackoff.Duration
+	}
+	if policy.MaxBackoff != nil && policy.MaxBackoff.Duration > 0 {
+		maxBackoff = policy.MaxBackoff.Duration
+	}
+	if policy.Deadline != nil && policy.Deadline.Duration > 0 {
+		deadline = policy.Deadline.Duration
+	}
+	return
+}
+
+// retryTest calls step until it no longer asks for a retry, following the retry policy of the test.
+// A step returning an error is a failed attempt, retried after an exponential backoff. Other retries move
+// the test on to its next phase and are retried right away. An error is returned once the policy is exhausted.
+// Every step reports the progress of the reconcile to the health watchdog
+func retryTest(instance *k8sv1alpha1.Coastie, reqLogger logr.Logger, testName string, step func() (retry bool, err error)) error {
+	maxAttempts, backoff, maxBackoff, deadline := retryPolicy(instance, testName)
+	giveUp := time.Now().Add(deadline)
+	failed := 0
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}}
+	for {
+		health.Reconciles.Progress(controllerName, request)
+		retry, err := step()
+		if !retry {
+			return err
+		}
+		if time.Now().After(giveUp) {
+			if err == nil {
+				err = fmt.Errorf("test did not complete")
+			}
+			return fmt.Errorf("deadline of %s exceeded: %w", deadline, err)
+		}
+		if err == nil {
+			continue
+		}
+		failed++
+		if failed >= maxAttempts {
+			return fmt.Errorf("gave up after %d failed attempts: %w", failed, err)
+		}
+		wait := backoff
+		if remaining := time.Until(giveUp); wait > remaining {
+			wait = remaining
+		}
+		reqLogger.Info("Attempt failed, backing off", "TestName", strings.ToUpper(testName), "FailedAttempts", failed, "Backoff", wait)
+		time.Sleep(wait)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// giveUpTest marks a test Failed once its retry policy is exhausted, records its run and alerts on it. Failed
+// attempts only report their status, so a test giving up is recorded and alerted on once
+func giveUpTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, reason error) {
+	reqLogger.Error(reason, "Giving up on Test", "TestName", strings.ToUpper(testName))
+	message := fmt.Sprintf("Coastie Operator: Giving up on %s Test, %s", strings.ToUpper(testName), reason)
+	TestStatus := instance.Status.TestResults[testName]
+	TestStatus.Status = "Failed"
+	// The run holds the node results of the last failed attempt
+	var nodes []k8sv1alpha1.NodeResult
+	var failure *testFailure
+	if errors.As(reason, &failure) {
+		nodes = failure.nodes
+	}
+	err := completeTest(ctx, instance, r, reqLogger, testName, TestStatus, message, nodes)
+	if err != nil {
+		reqLogger.Error(err, "Failed to mark Test as failed", "TestName", strings.ToUpper(testName))
+	}
+	err = notify(ctx, instance, r, testName, message)
+	if err != nil {
+		reqLogger.Error(err, "Failed to send slack message")
+	}
+}
@@ -0,0 +1,222 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Retention used when the Coastie does not set a RunHistory
+const (
+	defaultRunHistoryLimit  = 100
+	defaultRunHistoryMaxAge = 720 * time.Hour
+)
+
+// completeTest records the CoastieRun of a finished test execution, refreshes the conditions of the test and
+// its availability when the Coastie has an SLO, and saves the result to the Coastie status
+func completeTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, TestStatus k8sv1alpha1.TestResult, message string, nodes []k8sv1alpha1.NodeResult) (err error) {
+	setTestConditions(&TestStatus, message)
+	TestStatus.LastRun, err = recordRun(ctx, instance, r, reqLogger, testName, TestStatus.Status, message, TestStatus.Latency, nodes)
+	if err != nil {
+		reqLogger.Error(err, "Failed to record CoastieRun", "TestName", strings.ToUpper(testName))
+	}
+	if instance.Spec.SLO != nil {
+		availability, err := computeAvailability(ctx, instance, r, reqLogger, testName, TestStatus.Status == "Failed", TestStatus.Availability)
+		if err != nil {
+			reqLogger.Error(err, "Failed to compute availability", "TestName", strings.ToUpper(testName))
+		} else {
+			TestStatus.Availability = availability
+		}
+	}
+	return updateCoastieStatus(instance, TestStatus, testName, reqLogger, r)
+}
+
+// testFailure is a failed attempt of a test. The status and node results of the last attempt are recorded
+// once the retry policy gives up
+type testFailure struct {
+	testName string
+	status   string
+	nodes    []k8sv1alpha1.NodeResult
+}
+
+func (f *testFailure) Error() string {
+	return fmt.Sprintf("%s Test failed: %s", strings.ToUpper(f.testName), f.status)
+}
+
+// failTest reports a failed attempt in the status of a test and asks for a retry. Neither a CoastieRun nor an
+// alert is sent, giveUpTest does both once, when the retry policy is exhausted
+func failTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string, TestStatus k8sv1alpha1.TestResult, status string, nodes []k8sv1alpha1.NodeResult) (err error, retry bool) {
+	TestStatus.Status = "Failed"
+	// Degraded is left as the previous run set it, so a failed attempt followed by a slow one warns only once
+	setAvailableCondition(&TestStatus, status)
+	err = updateCoastieStatus(instance, TestStatus, testName, reqLogger, r)
+	if err != nil {
+		return err, retry
+	}
+	// Requeue, the retry policy backs off before the next attempt
+	retry = true
+	return &testFailure{testName: testName, status: status, nodes: nodes}, retry
+}
+
+// recordRun creates a CoastieRun for a finished test execution and prunes the runs past the retention policy.
+// The run starts when the DaemonSet of the test was created
+func recordRun(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName, result, message string, latency *metav1.Duration, nodes []k8sv1alpha1.NodeResult) (name string, err error) {
+	now := time.Now()
+	start := now
+	dsct := instance.Status.TestResults[testName].DaemonSetCreationTime
+	if t, err := time.Parse(time.RFC3339, dsct); err == nil {
+		start = t
+	}
+
+	run := &k8sv1alpha1.CoastieRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-%s-", instance.Name, testName),
+			Namespace:    instance.Namespace,
+			Labels: map[string]string{
+				k8sv1alpha1.CoastieRunCoastieLabel: instance.Name,
+				k8sv1alpha1.CoastieRunTestLabel:    testName,
+			},
+		},
+		Spec: k8sv1alpha1.CoastieRunSpec{
+			Coastie: instance.Name,
+			Test:    testName,
+		},
+		Status: k8sv1alpha1.CoastieRunStatus{
+			StartTime: metav1.NewTime(start),
+			EndTime:   metav1.NewTime(now),
+			Result:    result,
+			Message:   message,
+			Latency:   latency,
+			Nodes:     nodes,
+		},
+	}
+	// Set Coastie instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, run, r.scheme); err != nil {
+		return "", err
+	}
+	err = r.client.Create(ctx, run)
+	if err != nil {
+		return "", err
+	}
+	reqLogger.Info("Recorded CoastieRun", "CoastieRun.Namespace", run.Namespace, "CoastieRun.Name", run.Name, "TestName", strings.ToUpper(testName), "Result", result)
+
+	err = pruneRuns(ctx, instance, r, reqLogger, testName)
+	if err != nil {
+		reqLogger.Error(err, "Failed to prune CoastieRuns", "TestName", strings.ToUpper(testName))
+	}
+	return run.Name, nil
+}
+
+// listRuns returns the CoastieRuns of a test, newest first
+func listRuns(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, testName string) (runs []k8sv1alpha1.CoastieRun, err error) {
+	opts := &client.ListOptions{}
+	opts.SetLabelSelector(fmt.Sprintf("%s=%s,%s=%s", k8sv1alpha1.CoastieRunCoastieLabel, instance.Name, k8sv1alpha1.CoastieRunTestLabel, testName))
+	opts.InNamespace(instance.Namespace)
+
+	runList := &k8sv1alpha1.CoastieRunList{}
+	err = r.client.List(ctx, opts, runList)
+	if err != nil {
+		return nil, err
+	}
+	runs = runList.Items
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Status.StartTime.After(runs[j].Status.StartTime.Time)
+	})
+	return runs, nil
+}
+
+// pruneRuns deletes the CoastieRuns of a test exceeding the RunHistory limit or max age. The runs are listed
+// from the cache of the manager, and the availability of an SLO is counted in the status, not from the runs
+func pruneRuns(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, testName string) (err error) {
+	runs, err := listRuns(ctx, instance, r, testName)
+	if err != nil {
+		return err
+	}
+	limit, maxAge := runRetention(instance)
+	for _, run := range expiredRuns(runs, limit, maxAge, time.Now()) {
+		reqLogger.Info("Deleting CoastieRun past retention", "CoastieRun.Namespace", run.Namespace, "CoastieRun.Name", run.Name)
+		err = r.client.Delete(ctx, run)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRetention returns the number of CoastieRuns kept per test and how long they are kept, with the defaults
+// applied
+func runRetention(instance *k8sv1alpha1.Coastie) (limit int, maxAge time.Duration) {
+	limit = defaultRunHistoryLimit
+	maxAge = defaultRunHistoryMaxAge
+	if history := instance.Spec.RunHistory; history != nil {
+		if history.Limit > 0 {
+			limit = history.Limit
+		}
+		if history.MaxAge != nil && history.MaxAge.Duration > 0 {
+			maxAge = history.MaxAge.Duration
+		}
+	}
+	return limit, maxAge
+}
+
+// expiredRuns returns the runs, sorted newest first, past the limit or older than maxAge
+func expiredRuns(runs []k8sv1alpha1.CoastieRun, limit int, maxAge time.Duration, now time.Time) (expired []*k8sv1alpha1.CoastieRun) {
+	for i, run := range runs {
+		if i < limit && now.Sub(run.Status.StartTime.Time) < maxAge {
+			continue
+		}
+		expired = append(expired, &runs[i])
+	}
+	return expired
+}
+
+// probePods runs probe against the pod of the test DaemonSet on every node, and returns the per node results
+// along with the nodes that failed. Every probe gets a span of its own
+func probePods(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, testName, name, namespace, dsct string, reqLogger logr.Logger, probe func(pod corev1.Pod) (status string)) (nodes []k8sv1alpha1.NodeResult, failed []string) {
+	startup := getPodsReadyTime(r, name, namespace, reqLogger, dsct)
+	for _, pod := range listTestPods(r, name, namespace) {
+		node := k8sv1alpha1.NodeResult{
+			NodeName: pod.Spec.NodeName,
+			PodName:  pod.Name,
+		}
+		if d, ok := startup[pod.Name]; ok {
+			node.StartupLatency = &metav1.Duration{Duration: d}
+		}
+		_, span := startTestSpan(ctx, "Probe node", instance, testName, tracing.NodeKey.String(pod.Spec.NodeName), tracing.TargetKey.String(pod.Status.PodIP))
+		start := time.Now()
+		status := probe(pod)
+		node.Latency = &metav1.Duration{Duration: time.Since(start)}
+		endProbeSpan(span, status)
+		if strings.Contains(status, "SUCCESS") {
+			node.Status = "Passed"
+		} else {
+			node.Status = "Failed"
+			node.Message = status
+			failed = append(failed, pod.Spec.NodeName)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, failed
+}
+
+// missingNodeResults returns a Failed result for every node the test DaemonSet has no pod on
+func missingNodeResults(nodeNames []string) (nodes []k8sv1alpha1.NodeResult) {
+	for _, v := range nodeNames {
+		nodes = append(nodes, k8sv1alpha1.NodeResult{
+			NodeName: v,
+			Status:   "Failed",
+			Message:  "No ready pod on node",
+		})
+	}
+	return nodes
+}
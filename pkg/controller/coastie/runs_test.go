@@ -0,0 +1,129 @@
+package coastie
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExpiredRuns(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	// runs returns runs started ago, newest first as listed by listRuns
+	runs := func(ago ...time.Duration) (runs []k8sv1alpha1.CoastieRun) {
+		for i, v := range ago {
+			run := k8sv1alpha1.CoastieRun{}
+			run.Name = fmt.Sprintf("run-%d", i)
+			run.Status.StartTime = metav1.NewTime(now.Add(-v))
+			runs = append(runs, run)
+		}
+		return runs
+	}
+
+	tests := []struct {
+		name    string
+		runs    []k8sv1alpha1.CoastieRun
+		limit   int
+		maxAge  time.Duration
+		expired []string
+	}{
+		{
+			name:   "no runs",
+			limit:  3,
+			maxAge: time.Hour,
+		},
+		{
+			name:   "within retention",
+			runs:   runs(time.Minute, 2*time.Minute, 3*time.Minute),
+			limit:  3,
+			maxAge: time.Hour,
+		},
+		{
+			name:    "past the limit",
+			runs:    runs(time.Minute, 2*time.Minute, 3*time.Minute, 4*time.Minute),
+			limit:   2,
+			maxAge:  time.Hour,
+			expired: []string{"run-2", "run-3"},
+		},
+		{
+			name:    "too old",
+			runs:    runs(time.Minute, 2*time.Hour, 3*time.Hour),
+			limit:   10,
+			maxAge:  time.Hour,
+			expired: []string{"run-1", "run-2"},
+		},
+		{
+			name:    "run exactly max age old",
+			runs:    runs(time.Minute, time.Hour),
+			limit:   10,
+			maxAge:  time.Hour,
+			expired: []string{"run-1"},
+		},
+		{
+			name:    "past the limit and too old",
+			runs:    runs(time.Minute, 2*time.Minute, 2*time.Hour),
+			limit:   1,
+			maxAge:  time.Hour,
+			expired: []string{"run-1", "run-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expired []string
+			for _, v := range expiredRuns(tt.runs, tt.limit, tt.maxAge, now) {
+				expired = append(expired, v.Name)
+			}
+			if !reflect.DeepEqual(expired, tt.expired) {
+				t.Errorf("expected %v to expire, got %v", tt.expired, expired)
+			}
+		})
+	}
+}
+
+func TestRunRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		history *k8sv1alpha1.RunHistory
+		slo     *k8sv1alpha1.SLO
+		limit   int
+		maxAge  time.Duration
+	}{
+		{
+			name:   "defaults",
+			limit:  defaultRunHistoryLimit,
+			maxAge: defaultRunHistoryMaxAge,
+		},
+		{
+			name:   "an slo keeps the default limit",
+			slo:    &k8sv1alpha1.SLO{Objective: "99.9", Windows: []metav1.Duration{{Duration: 2160 * time.Hour}}},
+			limit:  defaultRunHistoryLimit,
+			maxAge: defaultRunHistoryMaxAge,
+		},
+		{
+			name:    "run history",
+			history: &k8sv1alpha1.RunHistory{Limit: 500, MaxAge: &metav1.Duration{Duration: 168 * time.Hour}},
+			limit:   500,
+			maxAge:  168 * time.Hour,
+		},
+		{
+			name:    "limit only",
+			history: &k8sv1alpha1.RunHistory{Limit: 10},
+			limit:   10,
+			maxAge:  defaultRunHistoryMaxAge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &k8sv1alpha1.Coastie{}
+			instance.Spec.RunHistory = tt.history
+			instance.Spec.SLO = tt.slo
+			limit, maxAge := runRetention(instance)
+			if limit != tt.limit || maxAge != tt.maxAge {
+				t.Errorf("expected a limit of %d and a max age of %s, got %d and %s", tt.limit, tt.maxAge, limit, maxAge)
+			}
+		})
+	}
+}
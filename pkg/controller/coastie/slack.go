@@ -1,10 +1,30 @@
 package coastie
 
 import (
+	"context"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
 	"github.com/nlopes/slack"
 )
 
-func notifySlack(token, channelID, message string) (err error) {
+// notify sends message about a test to the slack channel of the Coastie, or to the default notifier of the
+// operator when the Coastie does not set one. Nothing is sent when neither is configured
+func notify(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, testName, message string) (err error) {
+	token, channelID := instance.Spec.SlackToken, instance.Spec.SlackChannelID
+	if token == "" && channelID == "" {
+		token, channelID = r.config.Notifier.SlackToken, r.config.Notifier.SlackChannelID
+	}
+	if token == "" && channelID == "" {
+		return nil
+	}
+	return notifySlack(ctx, instance, testName, token, channelID, message)
+}
+
+// notifySlack posts message to channelID, in a span naming the Coastie and test it is about
+func notifySlack(ctx context.Context, instance *k8sv1alpha1.Coastie, testName, token, channelID, message string) (err error) {
+	_, span := startTestSpan(ctx, "Notify slack", instance, testName, tracing.TargetKey.String(channelID))
+	defer func() { endSpan(span, err) }()
 	api := slack.New(token)
 	params := slack.PostMessageParameters{}
 	params.LinkNames = 1
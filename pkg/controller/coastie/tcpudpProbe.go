@@ -0,0 +1,146 @@
+package coastie
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// Largest response read from a single read on the connection
+const maxTcpUdpRead = 64 * 1024
+
+// tcpudpProbe is what a tcp or udp probe sends, and the response it expects, decoded from the test settings
+type tcpudpProbe struct {
+	host string
+	port int32
+	// network restricts the probe to an IP family, tcp4 for example, any family is dialed when empty
+	network string
+	payload []byte
+	// match is exact, prefix or regex, any response is accepted when empty
+	match  string
+	expect []byte
+	regex  *regexp.Regexp
+}
+
+// newTcpUdpProbe returns the probe of a tcp or udp test, the question and answer of the bundled echo server
+// listening on echoPort unless the test settings say otherwise
+func newTcpUdpProbe(instance *k8sv1alpha1.Coastie, tcpudp string, echoPort int32) (probe tcpudpProbe, err error) {
+	probe = tcpudpProbe{
+		port:  echoPort,
+		match: "exact",
+	}
+	if tcpudp == "tcp" {
+		probe.payload = []byte("Annie, are you ok?\n\n")
+		probe.expect = []byte("So, Annie are you ok?\n")
+	} else {
+		probe.payload = []byte("ruok?\n")
+		probe.expect = []byte("imok\n")
+	}
+	settings := testSettings(instance, tcpudp).TcpUdp
+	if settings == nil {
+		return probe, nil
+	}
+
+	if settings.Port != 0 {
+		if settings.Host == "" {
+			return probe, fmt.Errorf("invalid %s settings: port can only be set along with host", tcpudp)
+		}
+		if settings.Port < 1 || settings.Port > 65535 {
+			return probe, fmt.Errorf("invalid %s settings: port %d must be between 1 and 65535", tcpudp, settings.Port)
+		}
+		probe.port = settings.Port
+	}
+	probe.host = settings.Host
+	if settings.Payload != "" {
+		probe.payload, err = decodePayload(settings.Encoding, settings.Payload)
+		if err != nil {
+			return probe, fmt.Errorf("invalid %s settings: payload: %s", tcpudp, err)
+		}
+		// A custom payload accepts any response unless told otherwise
+		probe.match = ""
+		probe.expect = nil
+	}
+	if settings.Expect != nil {
+		probe.match = settings.Expect.Match
+		switch probe.match {
+		case "", "exact", "prefix":
+			if probe.match == "" {
+				probe.match = "exact"
+			}
+			probe.expect, err = decodePayload(settings.Encoding, settings.Expect.Value)
+			if err != nil {
+				return probe, fmt.Errorf("invalid %s settings: expect: %s", tcpudp, err)
+			}
+		case "regex":
+			probe.regex, err = regexp.Compile(settings.Expect.Value)
+			if err != nil {
+				return probe, fmt.Errorf("invalid %s settings: expect: %s", tcpudp, err)
+			}
+		default:
+			return probe, fmt.Errorf("invalid %s settings: expect match %q must be one of exact, prefix or regex", tcpudp, probe.match)
+		}
+	}
+	return probe, nil
+}
+
+// decodePayload decodes value as text or base64
+func decodePayload(encoding, value string) ([]byte, error) {
+	switch encoding {
+	case "", "text":
+		return []byte(value), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return nil, fmt.Errorf("encoding %q must be text or base64", encoding)
+}
+
+// check returns whether enough of the response was read to decide on it, and whether it is the expected one
+func (p tcpudpProbe) check(response []byte) (done, ok bool) {
+	switch p.match {
+	case "":
+		return len(response) > 0, true
+	case "exact":
+		if len(response) >= len(p.expect) {
+			return true, bytes.Equal(response, p.expect)
+		}
+		return !bytes.HasPrefix(p.expect, response), false
+	case "prefix":
+		if len(response) >= len(p.expect) {
+			return true, bytes.HasPrefix(response, p.expect)
+		}
+		return !bytes.HasPrefix(p.expect, response), false
+	case "regex":
+		return p.regex.Match(response), true
+	}
+	return true, false
+}
+
+// read reads from c until the response can be decided on, the read deadline is hit or the connection is closed
+func (p tcpudpProbe) read(c net.Conn) (response []byte, ok bool, err error) {
+	buf := make([]byte, maxTcpUdpRead)
+	for {
+		n, err := c.Read(buf)
+		response = append(response, buf[:n]...)
+		if done, ok := p.check(response); done {
+			return response, ok, nil
+		}
+		if err != nil {
+			return response, false, err
+		}
+	}
+}
+
+// expected describes the expected response, for failure messages
+func (p tcpudpProbe) expected() string {
+	switch p.match {
+	case "":
+		return "any response"
+	case "regex":
+		return fmt.Sprintf("a response matching %q", p.regex.String())
+	}
+	return fmt.Sprintf("%s %q", p.match, p.expect)
+}
@@ -1,7 +1,6 @@
 package coastie
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"net"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/go-logr/logr"
 	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -19,11 +19,42 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, tcpudp string) (err error, retry bool) {
+func runTcpUdpTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, tcpudp string) (err error, retry bool) {
 	retry = false
 	name := fmt.Sprintf("%s-%s", instance.Name, tcpudp)
+	timeouts := testTimeouts(instance, r, tcpudp)
 	// Define a new DaemonSet object
-	DaemonSet, containerPort := tcpudpServer(instance, name, tcpudp)
+	DaemonSet, containerPort := tcpudpServer(instance, name, tcpudp, r.config.AgentImage)
+	probe, err := newTcpUdpProbe(instance, tcpudp, containerPort)
+	if err != nil {
+		return err, retry
+	}
+	burst, err := testUDPBurst(instance, tcpudp)
+	if err != nil {
+		return err, retry
+	}
+	families, err := testIPFamilies(instance, tcpudp)
+	if err != nil {
+		return err, retry
+	}
+	expose, err := testExposeSettings(instance, tcpudp)
+	if err != nil {
+		return err, retry
+	}
+	if probe.host != "" {
+		// Probing a server of our own, the echo servers are not needed
+		return runTcpUdpHostTest(ctx, instance, r, reqLogger, tcpudp, probe, families, timeouts)
+	}
+	// Every phase the test goes through gets its own span, the current one is ended on return
+	testCtx := ctx
+	ctx, span := startTestSpan(testCtx, "DaemonSet", instance, tcpudp)
+	defer func() { endSpan(span, err) }()
+	if burst != nil {
+		// The agent echoing the burst needs the Secret holding the token of its control API to start
+		if _, err := ensureAgentToken(ctx, instance, r, reqLogger); err != nil {
+			return err, retry
+		}
+	}
 	// Set Coastie instance as the owner and controller
 	if err := controllerutil.SetControllerReference(instance, DaemonSet, r.scheme); err != nil {
 		return err, retry
@@ -32,10 +63,10 @@ func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger
 	// Check if this DaemonSet already exists
 	TestStatus := instance.Status.TestResults[tcpudp]
 	found := &appsv1.DaemonSet{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
 	if err != nil && errors.IsNotFound(err) {
 		reqLogger.Info("Creating a new DaemonSet", "DaemonSet.Namespace", DaemonSet.Namespace, "DaemonSet.Name", name)
-		err = r.client.Create(context.TODO(), DaemonSet)
+		err = r.client.Create(ctx, DaemonSet)
 		if err != nil {
 			return err, retry
 		}
@@ -45,6 +76,8 @@ func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger
 
 		TestStatus.DaemonSetCreationTime = dsct
 		TestStatus.Status = "Running"
+		TestStatus.Latency = nil
+		TestStatus.LoadBalancerAllocation = nil
 		err = updateCoastieStatus(instance, TestStatus, tcpudp, reqLogger, r)
 		if err != nil {
 			return err, retry
@@ -60,68 +93,108 @@ func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger
 	if found.Status.DesiredNumberScheduled == found.Status.NumberReady {
 		// All pods are now running, run test against them
 		// Spin up service
+		span.End()
+		ctx, span = startTestSpan(testCtx, "Service", instance, tcpudp)
 		tcpudpService := tcpudpServerService(instance, name, tcpudp)
 		// Set Coastie instance as the owner and controller
 		if err := controllerutil.SetControllerReference(instance, tcpudpService, r.scheme); err != nil {
 			return err, retry
 		}
-		// Check if Service exists
-		err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, tcpudpService)
-		if err != nil && errors.IsNotFound(err) {
-			reqLogger.Info("Creating a new Service", "Service.Namespace", tcpudpService.Namespace, "Service.Name", name)
-			err = r.client.Create(context.TODO(), tcpudpService)
+		// The NodePort and LoadBalancer Services are probed last, giving them the most time to be set up
+		for _, service := range expose.services(instance, name, tcpudp) {
+			err = createIfNotFound(ctx, instance, r, reqLogger, service)
 			if err != nil {
 				return err, retry
 			}
+			// A Service created just now has its UID, follow the allocation of its external IP from here on
+			if service.Spec.Type == corev1.ServiceTypeLoadBalancer && service.UID != "" {
+				watchLoadBalancer(r, reqLogger, service, expose.loadBalancerTimeout)
+			}
+		}
+		// Check if Service exists
+		clusterIPs, created, err := getOrCreateService(ctx, r, reqLogger, tcpudpService, families)
+		if err != nil {
+			return err, retry
+		}
+		if created {
 			// Service created successfully - return and requeue
 			retry = true
 			return nil, retry
-		} else if err != nil {
-			return err, retry
 		}
 		// Service Exists, how do we connect to it?
-		ServerClusterIP := tcpudpService.Spec.ClusterIP
+		span.End()
+		ctx, span = startTestSpan(testCtx, "Probe", instance, tcpudp)
 		reqLogger.Info("Service exists, trying connection", "Service.Namespace", tcpudpService.Namespace, "Service.Name", name)
-		// Use client to connect to service, try 5 times if fail
-		// If this is still true later, fail with message
-		Fail := true
-		Status := ""
-		i := 0
-		for i < 5 {
-			Status = tcpudpClient(ServerClusterIP, tcpudp, containerPort, reqLogger)
-			if strings.Contains(Status, "SUCCESS") {
-				TestStatus.Status = "Passed"
-				err = updateCoastieStatus(instance, TestStatus, tcpudp, reqLogger, r)
-				if err != nil {
-					return err, retry
+		// Use client to connect to service, try again if fail, once per IP family
+		var probeLatency time.Duration
+		Status := probeFamilies(families, func(family string) (Status string) {
+			ServerClusterIP := clusterIPs[family]
+			if ServerClusterIP == "" {
+				return fmt.Sprintf("ERROR: %s Service has no ClusterIP", strings.ToUpper(tcpudp))
+			}
+			i := 0
+			for i < timeouts.probeAttempts {
+				_, attemptSpan := startTestSpan(ctx, "Probe attempt", instance, tcpudp, tracing.AttemptKey.Int(i), tracing.TargetKey.String(ServerClusterIP))
+				start := time.Now()
+				Status = tcpudpClient(ServerClusterIP, tcpudp, probe, timeouts, reqLogger)
+				probeLatency = time.Since(start)
+				endProbeSpan(attemptSpan, Status)
+				if strings.Contains(Status, "SUCCESS") {
+					// Exit loop
+					reqLogger.Info("Test client connected successfully", "Service.Namespace", tcpudpService.Namespace, "Service.Name", name, "ClusterIP", ServerClusterIP)
+					i = timeouts.probeAttempts
+				} else {
+					// Pods are running, but failing test, give them a few seconds
+					reqLogger.Info("Test client failed, sleeping and trying again", "ClientAttempt", i, "ProbeInterval", timeouts.probeInterval, "Service.Namespace", tcpudpService.Namespace, "Service.Name", name, "ClusterIP", ServerClusterIP)
+					i++
+					time.Sleep(timeouts.probeInterval)
 				}
-				Fail = false
-				// Exit loop
-				reqLogger.Info("Test client connected successfully", "Service.Namespace", tcpudpService.Namespace, "Service.Name", name)
-				i = 5
-			} else {
-				// Pods are running, but failing test, give them a few seconds
-				reqLogger.Info("Test client failed, sleeping for 2 seconds and trying again", "ClientAttempt", i, "Service.Namespace", tcpudpService.Namespace, "Service.Name", name)
-				i++
-				time.Sleep(2 * time.Second)
 			}
+			return Status
+		})
+		// If this is still true later, fail with message
+		Fail := !strings.Contains(Status, "SUCCESS")
+		// Connect to the pod on every node directly, to tell which nodes are failing
+		dsct := instance.Status.TestResults[tcpudp].DaemonSetCreationTime
+		podIPs := podFamilyIPs(ctx, r, name, found.Namespace, families)
+		nodes, failedNodes := probePods(ctx, instance, r, tcpudp, name, found.Namespace, dsct, reqLogger, func(pod corev1.Pod) string {
+			return probeFamilies(families, func(family string) string {
+				ip := familyPodIP(pod, podIPs, family)
+				if ip == "" {
+					return fmt.Sprintf("ERROR: %s pod has no IP", strings.ToUpper(tcpudp))
+				}
+				return tcpudpClient(ip, tcpudp, probe, timeouts, reqLogger)
+			})
+		})
+		if burst != nil {
+			failedNodes = append(failedNodes, probeUDPBursts(ctx, instance, r, name, found.Namespace, burst, timeouts, reqLogger, nodes)...)
 		}
-		if Fail {
-			TestStatus.Status = "Failed"
-			err = updateCoastieStatus(instance, TestStatus, tcpudp, reqLogger, r)
-			if err != nil {
-				return err, retry
+		if expose != nil && expose.nodePort {
+			var nodePortFailed []string
+			nodes, nodePortFailed = probeNodePorts(ctx, instance, r, reqLogger, name, tcpudp, probe, timeouts, nodes)
+			failedNodes = append(failedNodes, nodePortFailed...)
+		}
+		if !Fail && len(failedNodes) > 0 {
+			Fail = true
+			Status = fmt.Sprintf("ERROR: %s Failed on nodes: %s", strings.ToUpper(tcpudp), failedNodes)
+		}
+		if expose != nil && expose.loadBalancer {
+			loadBalancerStatus, allocation := probeLoadBalancer(ctx, instance, r, reqLogger, name, tcpudp, probe, expose, timeouts)
+			if allocation != nil {
+				TestStatus.LoadBalancerAllocation = &metav1.Duration{Duration: *allocation}
+				setLoadBalancerMetric(instance, tcpudp, *allocation)
 			}
-			message := fmt.Sprintf("Coastie Operator: %s Test failed. %s", strings.ToUpper(tcpudp), Status)
-			// Alarm slack if failed
-			err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
-			if err != nil {
-				reqLogger.Error(err, "Failed to send slack message")
+			if !Fail && !strings.Contains(loadBalancerStatus, "SUCCESS") {
+				Fail = true
+				Status = loadBalancerStatus
 			}
-
-			// Requeue
-			retry = true
-			return nil, retry
+		}
+		if Fail {
+			return failTest(instance, r, reqLogger, tcpudp, TestStatus, Status, nodes)
+		}
+		err, retry = passTest(ctx, instance, r, reqLogger, tcpudp, TestStatus, Status, probeLatencies(probeLatency, nodes), nodes)
+		if err != nil {
+			return err, retry
 		}
 		//} else {
 		//	reqLogger.Info("DaemonSet is not ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
@@ -129,16 +202,19 @@ func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger
 		//	return nil, retry
 		//}
 	} else {
+		span.End()
+		ctx, span = startTestSpan(testCtx, "DaemonSet wait", instance, tcpudp)
+		polls, interval := rolloutPolls(timeouts.rollout)
 		i := 0
-		for i < 5 {
-			// Wait 60 seconds
-			time.Sleep(60 * time.Second)
+		for i < polls {
+			// Wait before checking the DaemonSet again
+			time.Sleep(interval)
 			found := &appsv1.DaemonSet{}
-			err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+			err = r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
 			if err != nil {
 				message := fmt.Sprintf("Coastie Operator: Failed to get DaemonSet status")
 				// Alarm slack if failed
-				err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
+				err := notify(ctx, instance, r, tcpudp, message)
 				if err != nil {
 					reqLogger.Error(err, "Failed to send slack message")
 					return err, retry
@@ -146,38 +222,73 @@ func runTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger
 			}
 			if found.Status.DesiredNumberScheduled == found.Status.NumberReady {
 				reqLogger.Info("DaemonSet is ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
-				i = 10
+				i = polls + 1
 			} else {
 				reqLogger.Info("DaemonSet is not ready", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", name)
 				i++
 			}
 		}
-		if i == 5 {
-			// If here, means Daemonset to not become ready within 5 minutes
+		if i == polls {
+			// If here, means Daemonset to not become ready within the rollout timeout
 
 			nodes := getNodesWithoutPods(r, name, instance.Namespace)
-			message := fmt.Sprintf("Coastie Operator: DaemonSet took longer than 5 minutes to become ready, nodes with issues: %s", nodes)
-			// Alarm slack if failed
-			err := notifySlack(instance.Spec.SlackToken, instance.Spec.SlackChannelID, message)
-			if err != nil {
-				reqLogger.Error(err, "Failed to send slack message")
-				return err, retry
-			}
-			retry = true
-			return nil, retry
+			Status := fmt.Sprintf("ERROR: DaemonSet took longer than %s to become ready, nodes with issues: %s", timeouts.rollout, nodes)
+			return failTest(instance, r, reqLogger, tcpudp, TestStatus, Status, missingNodeResults(nodes))
 		} else {
 			retry = true
 			return nil, retry
 		}
 	}
 
-	dsct := instance.Status.TestResults[tcpudp].DaemonSetCreationTime
-	getPodsReadyTime(r, name, found.Namespace, reqLogger, dsct)
 	reqLogger.Info("Reached end of Test", "TestName", strings.ToUpper(tcpudp))
 	return nil, retry
 }
 
-func tcpudpServer(cr *k8sv1alpha1.Coastie, name, tcpudp string) (ds *appsv1.DaemonSet, containerPort int32) {
+// runTcpUdpHostTest probes the host of the test settings instead of the bundled echo servers
+func runTcpUdpHostTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, tcpudp string, probe tcpudpProbe, families []string, timeouts timeouts) (err error, retry bool) {
+	ctx, span := startTestSpan(ctx, "Probe", instance, tcpudp, tracing.TargetKey.String(probe.host))
+	defer func() { endSpan(span, err) }()
+	// The run starts with the first probe, as there is no DaemonSet to create
+	TestStatus := instance.Status.TestResults[tcpudp]
+	TestStatus.DaemonSetCreationTime = time.Now().Format(time.RFC3339)
+	TestStatus.Status = "Running"
+	TestStatus.Latency = nil
+	err = updateCoastieStatus(instance, TestStatus, tcpudp, reqLogger, r)
+	if err != nil {
+		return err, retry
+	}
+
+	var probeLatency time.Duration
+	Status := probeFamilies(families, func(family string) (Status string) {
+		probe.network = familyNetwork(tcpudp, family)
+		for i := 0; i < timeouts.probeAttempts; i++ {
+			_, attemptSpan := startTestSpan(ctx, "Probe attempt", instance, tcpudp, tracing.AttemptKey.Int(i), tracing.TargetKey.String(probe.host))
+			start := time.Now()
+			Status = tcpudpClient(probe.host, tcpudp, probe, timeouts, reqLogger)
+			probeLatency = time.Since(start)
+			endProbeSpan(attemptSpan, Status)
+			if strings.Contains(Status, "SUCCESS") {
+				break
+			}
+			reqLogger.Info("Test client failed, sleeping and trying again", "ClientAttempt", i, "ProbeInterval", timeouts.probeInterval, "Host", probe.host, "IPFamily", family)
+			time.Sleep(timeouts.probeInterval)
+		}
+		return Status
+	})
+	if !strings.Contains(Status, "SUCCESS") {
+		return failTest(instance, r, reqLogger, tcpudp, TestStatus, Status, nil)
+	}
+	err, retry = passTest(ctx, instance, r, reqLogger, tcpudp, TestStatus, Status, probeLatencies(probeLatency, nil), nil)
+	if err != nil {
+		return err, retry
+	}
+	reqLogger.Info("Reached end of Test", "TestName", strings.ToUpper(tcpudp), "Host", probe.host)
+	return nil, retry
+}
+
+// tcpudpServer returns the DaemonSet running the tcp or udp server on every node, along with the agent echoing
+// the burst of the udp test when it sends one
+func tcpudpServer(cr *k8sv1alpha1.Coastie, name, tcpudp, agentImage string) (ds *appsv1.DaemonSet, containerPort int32) {
 	var image string
 	if tcpudp == "udp" {
 		containerPort = 8082
@@ -229,6 +340,9 @@ func tcpudpServer(cr *k8sv1alpha1.Coastie, name, tcpudp string) (ds *appsv1.Daem
 			},
 		},
 	}
+	if tcpudp == "udp" && testSettings(cr, tcpudp).Burst != nil {
+		ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, agentContainer(fmt.Sprintf("%s-agent", name), agentImage, agentTokenSecret(cr)))
+	}
 	return ds, containerPort
 }
 
@@ -265,70 +379,65 @@ func tcpudpServerService(cr *k8sv1alpha1.Coastie, name, tcpudp string) *corev1.S
 	}
 }
 
-func tcpudpClient(ip, tcpudp string, port int32, reqLogger logr.Logger) (status string) {
-	var question string
-	var expectedResponse string
-	if tcpudp == "tcp" {
-		question = fmt.Sprintln("Annie, are you ok?\n")
-		expectedResponse = "So, Annie are you ok?\n"
-	} else {
-		question = fmt.Sprintln("ruok?")
-		expectedResponse = "imok\n"
-	}
+func tcpudpClient(ip, tcpudp string, probe tcpudpProbe, timeouts timeouts, reqLogger logr.Logger) (status string) {
 	// Node + port
-	uri := fmt.Sprintf("%s:%d", ip, port)
+	uri := net.JoinHostPort(ip, fmt.Sprint(probe.port))
 	// Connect
+	network := tcpudp
+	if probe.network != "" {
+		network = probe.network
+	}
 	reqLogger.Info("Attempting connection", "URI", uri, "Test", tcpudp)
-	c, err := net.DialTimeout(tcpudp, uri, 10*time.Second)
+	c, err := net.DialTimeout(network, uri, timeouts.dial)
 	if err != nil {
 		status = fmt.Sprintf("ERROR: %s unable to connect", strings.ToUpper(tcpudp))
 		return
 	}
+	defer c.Close()
 	reqLogger.Info("Connection Successful", "URI", uri, "Test", tcpudp)
 	// Send message
-	reqLogger.Info("Client asking question", "Question", question, "Test", tcpudp)
-	_, err = c.Write([]byte(question))
+	reqLogger.Info("Client asking question", "Question", fmt.Sprintf("%q", probe.payload), "Test", tcpudp)
+	_, err = c.Write(probe.payload)
 	if err != nil {
 		status = fmt.Sprintf("ERROR: %s unable to ask question", strings.ToUpper(tcpudp))
 		return
 	}
 	// Read response
-	// Set a deadline to read the message of 2 seconds
-	c.SetReadDeadline(time.Now().Add(2 * time.Second))
-	message, err := bufio.NewReader(c).ReadString('\n')
-	if err != nil {
-		c.Close()
-		status = fmt.Sprintf("ERROR: %s Failed - Server: %s", strings.ToUpper(tcpudp), err)
-		return
-	}
-	reqLogger.Info("Client Got answer", "Answer", message, "Test", tcpudp)
-	if message == expectedResponse {
-		c.Close()
+	// Set a deadline to read the message
+	c.SetReadDeadline(time.Now().Add(timeouts.read))
+	message, ok, err := probe.read(c)
+	reqLogger.Info("Client Got answer", "Answer", fmt.Sprintf("%q", message), "Test", tcpudp)
+	if ok {
 		status = fmt.Sprintf("SUCCESS: %s is working", strings.ToUpper(tcpudp))
 		return
-	} else if message != "" {
-		c.Close()
-		status = fmt.Sprintf("ERROR: %s Failed - Server: %s", strings.ToUpper(tcpudp), message)
+	} else if len(message) == 0 && err != nil {
+		status = fmt.Sprintf("ERROR: %s Failed - Server: %s", strings.ToUpper(tcpudp), err)
 		return
 	}
-	status = "ERROR: Should never reach this"
+	status = fmt.Sprintf("ERROR: %s Failed - Server: answered %q, expected %s", strings.ToUpper(tcpudp), message, probe.expected())
 	return
 }
 
-func deleteTcpUdpTest(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, tcpudp string) (err error) {
-	err = nil
+func deleteTcpUdpTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, tcpudp string) (err error) {
 	name := fmt.Sprintf("%s-%s", instance.Name, tcpudp)
 	// Delete DaemonSet
-	DaemonSet, _ := tcpudpServer(instance, name, tcpudp)
-	err = r.client.Delete(context.TODO(), DaemonSet)
-	if err != nil {
+	DaemonSet, _ := tcpudpServer(instance, name, tcpudp, r.config.AgentImage)
+	err = r.client.Delete(ctx, DaemonSet)
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	// Delete Service
-	tcpudpService := tcpudpServerService(instance, name, tcpudp)
-	err = r.client.Delete(context.TODO(), tcpudpService)
-	if err != nil {
-		return err
+	// Delete Services
+	services := []*corev1.Service{
+		tcpudpServerService(instance, name, tcpudp),
+		tcpudpExternalService(instance, name, tcpudp, corev1.ServiceTypeNodePort),
+		tcpudpExternalService(instance, name, tcpudp, corev1.ServiceTypeLoadBalancer),
 	}
-	return
+	for _, service := range services {
+		err = r.client.Delete(ctx, service)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	stopLoadBalancerWatch(services[2])
+	return nil
 }
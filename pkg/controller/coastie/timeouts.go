@@ -0,0 +1,61 @@
+package coastie
+
+import (
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Timeouts used when the test does not set them, the rollout deadline defaults to the operator configuration
+const (
+	defaultProbeAttempts       = 5
+	defaultTcpUdpProbeInterval = 2 * time.Second
+	defaultHttpProbeInterval   = 6 * time.Second
+	defaultDialTimeout         = 10 * time.Second
+	defaultTcpUdpReadTimeout   = 2 * time.Second
+	defaultHttpReadTimeout     = 10 * time.Second
+)
+
+// timeouts of a test, with the defaults applied
+type timeouts struct {
+	rollout       time.Duration
+	probeAttempts int
+	probeInterval time.Duration
+	dial          time.Duration
+	read          time.Duration
+}
+
+// testTimeouts returns the timeouts of a test
+func testTimeouts(instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, testName string) timeouts {
+	t := timeouts{
+		rollout:       r.config.RolloutTimeout.Duration,
+		probeAttempts: defaultProbeAttempts,
+		probeInterval: defaultTcpUdpProbeInterval,
+		dial:          defaultDialTimeout,
+		read:          defaultTcpUdpReadTimeout,
+	}
+	if testName == "http" {
+		t.probeInterval = defaultHttpProbeInterval
+		t.read = defaultHttpReadTimeout
+	}
+	settings := testSettings(instance, testName).Timeouts
+	if settings == nil {
+		return t
+	}
+	if settings.ProbeAttempts > 0 {
+		t.probeAttempts = settings.ProbeAttempts
+	}
+	durationOrDefault(&t.rollout, settings.RolloutDeadline)
+	durationOrDefault(&t.probeInterval, settings.ProbeInterval)
+	durationOrDefault(&t.dial, settings.DialTimeout)
+	durationOrDefault(&t.read, settings.ReadTimeout)
+	return t
+}
+
+// durationOrDefault sets d to v when v is set
+func durationOrDefault(d *time.Duration, v *metav1.Duration) {
+	if v != nil && v.Duration > 0 {
+		*d = v.Duration
+	}
+}
@@ -0,0 +1,39 @@
+package coastie
+
+import (
+	"context"
+	"strings"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTestSpan starts a span for a phase of a test, carrying the Coastie and test as attributes
+func startTestSpan(ctx context.Context, name string, instance *k8sv1alpha1.Coastie, testName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		tracing.CoastieNamespaceKey.String(instance.Namespace),
+		tracing.CoastieNameKey.String(instance.Name),
+		tracing.TestKey.String(testName),
+	)
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it as failed when err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endProbeSpan ends the span of a probe, marking it as failed unless the probe status is a SUCCESS
+func endProbeSpan(span trace.Span, status string) {
+	if !strings.Contains(status, "SUCCESS") {
+		span.SetStatus(codes.Error, status)
+	}
+	span.End()
+}
@@ -0,0 +1,206 @@
+package coastie
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+)
+
+// Settings of the udp burst used when the test does not set them
+const (
+	defaultBurstCount          = 100
+	defaultBurstInterval       = 10 * time.Millisecond
+	defaultBurstSize           = 64
+	defaultBurstMaxLossPercent = 1.0
+)
+
+// Every datagram of a burst starts with its sequence number and the time it was sent
+const burstHeaderSize = 16
+
+// udpBurst settings of the udp test, with the defaults applied
+type udpBurst struct {
+	count          int
+	interval       time.Duration
+	size           int
+	maxLossPercent float64
+}
+
+// testUDPBurst returns the burst settings of a test, nil when it does not send a burst
+func testUDPBurst(instance *k8sv1alpha1.Coastie, testName string) (burst *udpBurst, err error) {
+	settings := testSettings(instance, testName)
+	if settings.Burst == nil {
+		return nil, nil
+	}
+	if testName != "udp" {
+		return nil, fmt.Errorf("invalid %s settings: burst only applies to the udp test", testName)
+	}
+	if settings.TcpUdp != nil && settings.TcpUdp.Host != "" {
+		return nil, fmt.Errorf("invalid %s settings: burst needs the bundled udp servers and can not be set along with host", testName)
+	}
+	burst = &udpBurst{
+		count:          defaultBurstCount,
+		interval:       defaultBurstInterval,
+		size:           defaultBurstSize,
+		maxLossPercent: defaultBurstMaxLossPercent,
+	}
+	if settings.Burst.Count < 0 {
+		return nil, fmt.Errorf("invalid %s settings: burst count %d can not be negative", testName, settings.Burst.Count)
+	} else if settings.Burst.Count > 0 {
+		burst.count = settings.Burst.Count
+	}
+	durationOrDefault(&burst.interval, settings.Burst.Interval)
+	if settings.Burst.Size != 0 {
+		if settings.Burst.Size < burstHeaderSize || settings.Burst.Size > 65507 {
+			return nil, fmt.Errorf("invalid %s settings: burst size %d must be between %d and 65507", testName, settings.Burst.Size, burstHeaderSize)
+		}
+		burst.size = settings.Burst.Size
+	}
+	if settings.Burst.MaxLossPercent != "" {
+		burst.maxLossPercent, err = strconv.ParseFloat(settings.Burst.MaxLossPercent, 64)
+		if err != nil || burst.maxLossPercent < 0 || burst.maxLossPercent > 100 {
+			return nil, fmt.Errorf("invalid %s settings: burst maxlosspercent %q must be a number between 0 and 100", testName, settings.Burst.MaxLossPercent)
+		}
+	}
+	return burst, nil
+}
+
+// burstResult is what came back of a burst
+type burstResult struct {
+	sent       int
+	received   int
+	duplicates int
+	// reordered counts the datagrams received after one sent later
+	reordered int
+	// jitter is the interarrival jitter of RFC 3550, computed from the round trip times
+	jitter time.Duration
+}
+
+func (b burstResult) lossPercent() float64 {
+	if b.sent == 0 {
+		return 0
+	}
+	return float64(b.sent-b.received) / float64(b.sent) * 100
+}
+
+// probeUDPBursts sends a burst to the agent next to the udp server of every node whose probe passed, and adds
+// the loss, reordering and jitter to the node results. It returns the nodes losing too much of their burst
+func probeUDPBursts(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, name, namespace string, burst *udpBurst, timeouts timeouts, reqLogger logr.Logger, nodes []k8sv1alpha1.NodeResult) (failed []string) {
+	podIPs := make(map[string]string)
+	for _, pod := range listTestPods(r, name, namespace) {
+		podIPs[pod.Name] = pod.Status.PodIP
+	}
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Status != "Passed" {
+			continue
+		}
+		_, span := startTestSpan(ctx, "Probe burst", instance, "udp", tracing.NodeKey.String(node.NodeName), tracing.TargetKey.String(podIPs[node.PodName]))
+		result, err := sendUDPBurst(podIPs[node.PodName], burst, timeouts)
+		status := "SUCCESS"
+		if err != nil {
+			status = fmt.Sprintf("ERROR: UDP burst failed: %s", err)
+		} else {
+			loss := result.lossPercent()
+			node.Metrics = map[string]string{
+				"sent":        strconv.Itoa(result.sent),
+				"received":    strconv.Itoa(result.received),
+				"losspercent": strconv.FormatFloat(loss, 'f', 2, 64),
+				"reordered":   strconv.Itoa(result.reordered),
+				"duplicates":  strconv.Itoa(result.duplicates),
+				"jitter":      result.jitter.String(),
+			}
+			if loss > burst.maxLossPercent {
+				status = fmt.Sprintf("ERROR: UDP lost %.2f%% of a burst of %d datagrams, above %g%%", loss, result.sent, burst.maxLossPercent)
+			}
+		}
+		endProbeSpan(span, status)
+		reqLogger.Info("UDP burst", "NodeName", node.NodeName, "Metrics", node.Metrics, "Status", status)
+		if status != "SUCCESS" {
+			node.Status = "Failed"
+			node.Message = status
+			failed = append(failed, node.NodeName)
+		}
+	}
+	return failed
+}
+
+// sendUDPBurst sends the datagrams of a burst to the echo of the agent at ip, and collects them as they come back
+func sendUDPBurst(ip string, burst *udpBurst, timeouts timeouts) (result burstResult, err error) {
+	c, err := net.DialTimeout("udp", net.JoinHostPort(ip, strconv.Itoa(agent.EchoPort)), timeouts.dial)
+	if err != nil {
+		return result, err
+	}
+	defer c.Close()
+
+	// Every datagram carries the time it was sent, so the receiver shares nothing with the sender
+	sendErr := make(chan error, 1)
+	go func() {
+		payload := make([]byte, burst.size)
+		for seq := 0; seq < burst.count; seq++ {
+			binary.BigEndian.PutUint64(payload[0:8], uint64(seq))
+			binary.BigEndian.PutUint64(payload[8:16], uint64(time.Now().UnixNano()))
+			if _, err := c.Write(payload); err != nil {
+				sendErr <- err
+				return
+			}
+			time.Sleep(burst.interval)
+		}
+		sendErr <- nil
+	}()
+
+	seen := make([]bool, burst.count)
+	highest := -1
+	var lastTransit time.Duration
+	var jitter float64
+	buf := make([]byte, burst.size+1)
+	c.SetReadDeadline(time.Now().Add(time.Duration(burst.count)*burst.interval + timeouts.read))
+	for result.received < burst.count {
+		n, err := c.Read(buf)
+		if err != nil {
+			// The deadline ends the burst, whatever did not make it back by then is lost
+			break
+		}
+		received := time.Now()
+		if n < burstHeaderSize {
+			continue
+		}
+		seq := int(binary.BigEndian.Uint64(buf[0:8]))
+		if seq < 0 || seq >= burst.count {
+			continue
+		}
+		if seen[seq] {
+			result.duplicates++
+			continue
+		}
+		seen[seq] = true
+		transit := received.Sub(time.Unix(0, int64(binary.BigEndian.Uint64(buf[8:16]))))
+		if result.received > 0 {
+			d := transit - lastTransit
+			if d < 0 {
+				d = -d
+			}
+			jitter += (float64(d) - jitter) / 16
+		}
+		lastTransit = transit
+		result.received++
+		if seq < highest {
+			result.reordered++
+		} else {
+			highest = seq
+		}
+	}
+	if err := <-sendErr; err != nil {
+		return result, err
+	}
+	result.sent = burst.count
+	result.jitter = time.Duration(jitter)
+	return result, nil
+}
@@ -0,0 +1,274 @@
+package coastie
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gorilla/websocket"
+	"github.com/jmainguy/coastie-operator/pkg/agent"
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	instr "k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Settings of the websocket test used when the test does not set them
+const (
+	defaultWebSocketDuration     = 1 * time.Minute
+	defaultWebSocketPingInterval = 10 * time.Second
+)
+
+// webSocketSettings of the websocket test, with the defaults applied
+type webSocketSettings struct {
+	duration     time.Duration
+	pingInterval time.Duration
+}
+
+// testWebSocketSettings returns the settings of the websocket test
+func testWebSocketSettings(instance *k8sv1alpha1.Coastie) (settings webSocketSettings, err error) {
+	settings = webSocketSettings{
+		duration:     defaultWebSocketDuration,
+		pingInterval: defaultWebSocketPingInterval,
+	}
+	if ws := testSettings(instance, "websocket").WebSocket; ws != nil {
+		durationOrDefault(&settings.duration, ws.Duration)
+		durationOrDefault(&settings.pingInterval, ws.PingInterval)
+	}
+	if settings.pingInterval >= settings.duration {
+		return settings, fmt.Errorf("invalid websocket settings: pinginterval %s must be shorter than duration %s", settings.pingInterval, settings.duration)
+	}
+	return settings, nil
+}
+
+func runWebSocketTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error, retry bool) {
+	settings, err := testWebSocketSettings(instance)
+	if err != nil {
+		return err, retry
+	}
+	name := fmt.Sprintf("%s-websocket", instance.Name)
+	timeouts := testTimeouts(instance, r, "websocket")
+	// The Service and Ingress are created along with the agents
+	objects := []runtime.Object{whoamiService(instance, name)}
+	if instance.Spec.HostURL != "" {
+		objects = append(objects, webSocketIngress(instance, name))
+	}
+	return runAgentTest(ctx, instance, r, reqLogger, "websocket", objects, func(ctx context.Context, _ *agent.Client, pods []corev1.Pod) (string, []time.Duration, []k8sv1alpha1.NodeResult) {
+		status, nodes := webSocketProbe(ctx, instance, r, reqLogger, name, settings, timeouts)
+		return status, nil, nodes
+	})
+}
+
+// webSocketProbe holds a WebSocket to the agents open through the ClusterIP, and the Ingress, at the same time.
+// Every connection makes a node result for the agent which accepted it
+func webSocketProbe(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger, name string, settings webSocketSettings, timeouts timeouts) (status string, nodes []k8sv1alpha1.NodeResult) {
+	service := &corev1.Service{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, service)
+	if err != nil {
+		return fmt.Sprintf("ERROR: WEBSOCKET Service not found: %s", err), nil
+	}
+	// Every path is named after the metric of the Service type, or Ingress, the connection went through
+	paths := [][2]string{{"clusterip", net.JoinHostPort(service.Spec.ClusterIP, "80")}}
+	if instance.Spec.HostURL != "" {
+		paths = append(paths, [2]string{"ingress", instance.Spec.HostURL})
+	}
+
+	holds := make([]webSocketHold, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			_, span := startTestSpan(ctx, "Hold connection", instance, "websocket", tracing.TargetKey.String(target))
+			holds[i] = holdWebSocket(fmt.Sprintf("ws://%s%s", target, agent.WebSocketPath), settings, timeouts)
+			endProbeSpan(span, holds[i].status())
+		}(i, path[1])
+	}
+	wg.Wait()
+
+	var problems []string
+	for i, path := range paths {
+		hold := holds[i]
+		node := k8sv1alpha1.NodeResult{
+			NodeName: hold.who.Node,
+			PodName:  hold.who.Pod,
+			Target:   path[0],
+			Status:   "Passed",
+			Metrics: map[string]string{
+				"held":  hold.held.String(),
+				"pings": strconv.Itoa(hold.pings),
+			},
+		}
+		reqLogger.Info("WebSocket held", "Path", path[0], "Target", path[1], "Held", hold.held, "Pings", hold.pings, "Status", hold.status())
+		if hold.err != nil {
+			node.Status = "Failed"
+			node.Message = strings.TrimPrefix(hold.status(), "ERROR: ")
+			if !hold.disconnectedAt.IsZero() {
+				node.Metrics["disconnectedat"] = hold.disconnectedAt.Format(time.RFC3339)
+			}
+			problems = append(problems, fmt.Sprintf("through the %s %s", path[0], node.Message))
+		}
+		nodes = append(nodes, node)
+	}
+	if len(problems) > 0 {
+		return fmt.Sprintf("ERROR: WEBSOCKET Failed, %s", strings.Join(problems, ", ")), nodes
+	}
+	return fmt.Sprintf("SUCCESS: WEBSOCKET held every connection for %s", settings.duration), nodes
+}
+
+// webSocketHold is how a WebSocket held up. A connection dropped before the end has the time it was found
+// dropped, a connection which could not be opened has none
+type webSocketHold struct {
+	who            agent.WhoAmI
+	pings          int
+	held           time.Duration
+	disconnectedAt time.Time
+	err            error
+}
+
+// status returns the status of the hold
+func (hold webSocketHold) status() string {
+	switch {
+	case hold.err == nil:
+		return "SUCCESS"
+	case hold.disconnectedAt.IsZero():
+		return fmt.Sprintf("ERROR: %s", hold.err)
+	}
+	return fmt.Sprintf("ERROR: disconnected after %s at %s: %s", hold.held, hold.disconnectedAt.Format(time.RFC3339), hold.err)
+}
+
+// holdWebSocket opens a WebSocket to url and holds it for the duration of the test, sending a message the agent
+// echoes every ping interval. The agent answers the connection with its WhoAmI. A message still not echoed by the
+// next ping drops the connection. The first connection is retried until url answers, as a new Ingress takes a
+// while to route
+func holdWebSocket(url string, settings webSocketSettings, timeouts timeouts) (hold webSocketHold) {
+	dialer := websocket.Dialer{HandshakeTimeout: timeouts.dial}
+	var c *websocket.Conn
+	var err error
+	for i := 0; i < timeouts.probeAttempts; i++ {
+		if c, _, err = dialer.Dial(url, nil); err == nil {
+			break
+		}
+		time.Sleep(timeouts.probeInterval)
+	}
+	if err != nil {
+		hold.err = fmt.Errorf("connecting: %s", err)
+		return hold
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(timeouts.read))
+	if err := c.ReadJSON(&hold.who); err != nil {
+		hold.err = fmt.Errorf("reading the agent: %s", err)
+		return hold
+	}
+	c.SetReadDeadline(time.Time{})
+
+	// Read in the background, so a dropped connection is found right away rather than at the next ping
+	start := time.Now()
+	echoes := make(chan error)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			_, _, err := c.ReadMessage()
+			select {
+			case echoes <- err:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	disconnected := func(err error) webSocketHold {
+		hold.held = time.Since(start)
+		hold.disconnectedAt = time.Now()
+		hold.err = err
+		return hold
+	}
+
+	ticker := time.NewTicker(settings.pingInterval)
+	defer ticker.Stop()
+	end := time.NewTimer(settings.duration)
+	defer end.Stop()
+	pending := false
+	for {
+		select {
+		case <-end.C:
+			hold.held = time.Since(start)
+			return hold
+		case err := <-echoes:
+			if err != nil {
+				return disconnected(err)
+			}
+			pending = false
+			hold.pings++
+		case <-ticker.C:
+			if pending {
+				return disconnected(fmt.Errorf("ping %d was not echoed within %s", hold.pings+1, settings.pingInterval))
+			}
+			c.SetWriteDeadline(time.Now().Add(timeouts.dial))
+			if err := c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("ping %d", hold.pings+1))); err != nil {
+				return disconnected(err)
+			}
+			pending = true
+		}
+	}
+}
+
+// webSocketIngress returns the Ingress routing the WebSocket path of the hosturl to the agents
+func webSocketIngress(cr *k8sv1alpha1.Coastie, name string) *extensionsv1beta1.Ingress {
+	return &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+		},
+		Spec: extensionsv1beta1.IngressSpec{
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: cr.Spec.HostURL,
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: agent.WebSocketPath,
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: name,
+										ServicePort: instr.FromInt(80),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func deleteWebSocketTest(ctx context.Context, instance *k8sv1alpha1.Coastie, r *ReconcileCoastie, reqLogger logr.Logger) (err error) {
+	name := fmt.Sprintf("%s-websocket", instance.Name)
+	err = deleteAgentTest(ctx, instance, r, reqLogger, "websocket")
+	if err != nil {
+		return err
+	}
+	// Delete Service and Ingress
+	for _, object := range []runtime.Object{whoamiService(instance, name), webSocketIngress(instance, name)} {
+		err = r.client.Delete(ctx, object)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package health serves the liveness and readiness endpoints of the operator. The operator is live as long as
+// reconciles keep making progress, see Watchdog, and ready while waiting to become the leader, then once the
+// caches of the manager are synced.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("health")
+
+// Server serves /healthz and /readyz on addr. It is started before the operator becomes the leader, so a pod
+// waiting for the leader lock is live and ready, and only reports the caches once it leads
+type Server struct {
+	addr    string
+	mux     *http.ServeMux
+	leading int32
+	synced  int32
+}
+
+// New creates a new health Server, the Reconciles watchdog reports the operator as unhealthy once a reconcile in
+// flight made no progress within window
+func New(addr string, window time.Duration) *Server {
+	Reconciles.SetWindow(window)
+	s := &Server{
+		addr: addr,
+		mux:  http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	return s
+}
+
+// Lead is called once the operator became the leader, the Server is then ready once the caches of mgr are synced
+func (s *Server) Lead(mgr manager.Manager) error {
+	atomic.StoreInt32(&s.leading, 1)
+	cache := mgr.GetCache()
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		if cache.WaitForCacheSync(stop) {
+			log.Info("Caches synced, operator is ready")
+			atomic.StoreInt32(&s.synced, 1)
+		}
+		return nil
+	}))
+}
+
+// Start serves the health endpoints until stop is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		log.Info("Serving health endpoints", "Address", s.addr)
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	case err := <-errChan:
+		return err
+	}
+}
+
+// healthz serves the liveness endpoint, failing while a reconcile is stuck so the operator gets restarted
+func (s *Server) healthz(w http.ResponseWriter, req *http.Request) {
+	if err := Reconciles.Check(); err != nil {
+		log.Info("Liveness check failed", "Reason", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz serves the readiness endpoint, ready but idle while waiting to become the leader so a rolling update
+// can replace the leader, then failing until the caches are synced
+func (s *Server) readyz(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&s.leading) == 0 {
+		fmt.Fprintln(w, "ok, waiting to become the leader")
+		return
+	}
+	if atomic.LoadInt32(&s.synced) == 0 {
+		http.Error(w, "caches are not synced", http.StatusServiceUnavailable)
+		return
+	}
+	if err := Reconciles.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reconciles is the watchdog of the reconciles of every controller, controllers wrap their Reconciler with Track
+// and report the progress of long reconciles with Progress
+var Reconciles = &Watchdog{
+	inFlight: make(map[int]inFlightReconcile),
+}
+
+// Watchdog tracks the reconciles in flight, and reports the operator as unhealthy once a reconcile in flight made
+// no progress within its window, meaning its worker is stuck. Each reconcile is watched on its own, so reconciles
+// of other Coasties completing do not hide a stuck one, and a reconcile running many tests stays healthy as long
+// as each of its phases completes within the window
+type Watchdog struct {
+	mu       sync.Mutex
+	window   time.Duration
+	nextID   int
+	inFlight map[int]inFlightReconcile
+}
+
+type inFlightReconcile struct {
+	controller string
+	request    reconcile.Request
+	started    time.Time
+	progressed time.Time
+}
+
+// SetWindow sets how long a reconcile in flight can make no progress before the operator is reported as
+// unhealthy, 0 disables the watchdog
+func (w *Watchdog) SetWindow(window time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.window = window
+}
+
+// Started records the start of a reconcile and returns the function recording its completion
+func (w *Watchdog) Started(controller string, request reconcile.Request) (completed func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	w.nextID++
+	now := time.Now()
+	w.inFlight[id] = inFlightReconcile{
+		controller: controller,
+		request:    request,
+		started:    now,
+		progressed: now,
+	}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.inFlight, id)
+	}
+}
+
+// Progress records that the reconcile of request by controller is still making progress, such as a test moving
+// on to its next phase. A controller never reconciles the same request twice at the same time
+func (w *Watchdog) Progress(controller string, request reconcile.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, v := range w.inFlight {
+		if v.controller == controller && v.request == request {
+			v.progressed = time.Now()
+			w.inFlight[id] = v
+		}
+	}
+}
+
+// Check returns an error when a reconcile in flight made no progress within the window, since it started or
+// since it last reported progress
+func (w *Watchdog) Check() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.window == 0 || len(w.inFlight) == 0 {
+		return nil
+	}
+	var stalest inFlightReconcile
+	for _, v := range w.inFlight {
+		if stalest.progressed.IsZero() || v.progressed.Before(stalest.progressed) {
+			stalest = v
+		}
+	}
+	now := time.Now()
+	if now.Sub(stalest.progressed) > w.window {
+		return fmt.Errorf("the %s reconcile of %s made no progress within the %s watchdog window, last progress %s ago, running for %s, %d in flight",
+			stalest.controller, stalest.request, w.window, now.Sub(stalest.progressed).Round(time.Second), now.Sub(stalest.started).Round(time.Second), len(w.inFlight))
+	}
+	return nil
+}
+
+// Track wraps r so its reconciles are tracked by the Reconciles watchdog
+func Track(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return &trackedReconciler{
+		controller: controller,
+		reconciler: r,
+	}
+}
+
+type trackedReconciler struct {
+	controller string
+	reconciler reconcile.Reconciler
+}
+
+func (t *trackedReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	completed := Reconciles.Started(t.controller, request)
+	defer completed()
+	return t.reconciler.Reconcile(request)
+}
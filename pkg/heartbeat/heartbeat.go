@@ -0,0 +1,138 @@
+// Package heartbeat periodically reports that the operator is alive, along with how many tests are passing,
+// to a webhook or to the default slack notifier. An external system alarms when the heartbeats stop.
+package heartbeat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/jmainguy/coastie-operator/pkg/config"
+	"github.com/nlopes/slack"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("heartbeat")
+
+// Timeout of a webhook request
+const webhookTimeout = 10 * time.Second
+
+// Heartbeat is the payload posted to the webhook
+type Heartbeat struct {
+	Text     string    `json:"text"`
+	Time     time.Time `json:"time"`
+	Passing  int       `json:"passing"`
+	Degraded int       `json:"degraded"`
+	Failing  int       `json:"failing"`
+	Tests    int       `json:"tests"`
+}
+
+// Sender sends a heartbeat every configured interval
+type Sender struct {
+	client client.Client
+	config *config.Config
+	http   *http.Client
+}
+
+// Add creates a new heartbeat Sender and adds it to the Manager when a heartbeat interval is configured
+func Add(mgr manager.Manager, cfg *config.Config) error {
+	if cfg.Heartbeat.Interval.Duration == 0 {
+		return nil
+	}
+	return mgr.Add(&Sender{
+		client: mgr.GetClient(),
+		config: cfg,
+		http:   &http.Client{Timeout: webhookTimeout},
+	})
+}
+
+// Start sends a heartbeat right away then every interval, until stop is closed
+func (s *Sender) Start(stop <-chan struct{}) error {
+	log.Info("Sending heartbeats", "Interval", s.config.Heartbeat.Interval.Duration)
+	ticker := time.NewTicker(s.config.Heartbeat.Interval.Duration)
+	defer ticker.Stop()
+	for {
+		if err := s.send(context.TODO()); err != nil {
+			log.Error(err, "Failed to send heartbeat")
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sender) send(ctx context.Context) error {
+	heartbeat, err := s.heartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	if s.config.Heartbeat.WebhookURL != "" {
+		return s.postWebhook(ctx, heartbeat)
+	}
+	api := slack.New(s.config.Notifier.SlackToken)
+	_, _, err = api.PostMessage(s.config.Notifier.SlackChannelID, slack.MsgOptionText(heartbeat.Text, false))
+	return err
+}
+
+// heartbeat counts the passing and failing tests of the watched Coasties
+func (s *Sender) heartbeat(ctx context.Context) (heartbeat Heartbeat, err error) {
+	coastieList := &k8sv1alpha1.CoastieList{}
+	err = s.client.List(ctx, &client.ListOptions{Namespace: s.config.Namespace()}, coastieList)
+	if err != nil {
+		return heartbeat, err
+	}
+	for _, instance := range coastieList.Items {
+		if !s.config.Watches(instance.Namespace) {
+			continue
+		}
+		for _, testName := range instance.Spec.Tests {
+			heartbeat.Tests++
+			switch instance.Status.TestResults[testName].Status {
+			case "Passed":
+				heartbeat.Passing++
+			case "Degraded":
+				heartbeat.Degraded++
+			case "Failed":
+				heartbeat.Failing++
+			}
+		}
+	}
+	heartbeat.Time = time.Now()
+	heartbeat.Text = fmt.Sprintf("Coastie Operator: still alive, %d of %d tests passing", heartbeat.Passing, heartbeat.Tests)
+	if heartbeat.Degraded > 0 {
+		heartbeat.Text = fmt.Sprintf("%s, %d degraded", heartbeat.Text, heartbeat.Degraded)
+	}
+	if heartbeat.Failing > 0 {
+		heartbeat.Text = fmt.Sprintf("%s, %d failing", heartbeat.Text, heartbeat.Failing)
+	}
+	return heartbeat, nil
+}
+
+func (s *Sender) postWebhook(ctx context.Context, heartbeat Heartbeat) error {
+	body, err := json.Marshal(heartbeat)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.config.Heartbeat.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("heartbeat webhook returned %s", resp.Status)
+	}
+	return nil
+}
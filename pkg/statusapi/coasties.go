@@ -0,0 +1,162 @@
+package statusapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	k8sv1alpha1 "github.com/jmainguy/coastie-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CoastieList is the response of /api/v1/coasties
+type CoastieList struct {
+	GeneratedAt time.Time `json:"generatedat"`
+	Items       []Coastie `json:"items"`
+}
+
+// Coastie is a Coastie along with the results of its tests
+type Coastie struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// ClusterCoastie is the name of the ClusterCoastie owning this Coastie, if any
+	ClusterCoastie string `json:"clustercoastie,omitempty"`
+	Tests          []Test `json:"tests"`
+}
+
+// Test is the latest result of a test, its availability and recent history
+type Test struct {
+	Name         string                           `json:"name"`
+	Status       string                           `json:"status"`
+	Availability []k8sv1alpha1.WindowAvailability `json:"availability,omitempty"`
+	// Latency is the percentile round trip time of the probes of the latest execution
+	Latency *metav1.Duration `json:"latency,omitempty"`
+	// Latest is the latest recorded run, holding the per node results
+	Latest *Run `json:"latest,omitempty"`
+	// History are the past runs, newest first, without per node results
+	History []Run `json:"history,omitempty"`
+}
+
+// Run is a single recorded execution of a test
+type Run struct {
+	Name      string                   `json:"name"`
+	StartTime metav1.Time              `json:"starttime"`
+	EndTime   metav1.Time              `json:"endtime"`
+	Result    string                   `json:"result"`
+	Message   string                   `json:"message,omitempty"`
+	Latency   *metav1.Duration         `json:"latency,omitempty"`
+	Nodes     []k8sv1alpha1.NodeResult `json:"nodes,omitempty"`
+}
+
+// coasties returns the Coasties along with their results, or the single Coastie namespace/name when name is set
+func (s *Server) coasties(ctx context.Context, namespace, name string, history int) (coasties []Coastie, err error) {
+	var items []k8sv1alpha1.Coastie
+	if name != "" {
+		instance := k8sv1alpha1.Coastie{}
+		err = s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &instance)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, instance)
+	} else {
+		coastieList := &k8sv1alpha1.CoastieList{}
+		err = s.client.List(ctx, &client.ListOptions{}, coastieList)
+		if err != nil {
+			return nil, err
+		}
+		items = coastieList.Items
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	for _, instance := range items {
+		coastie, err := s.coastie(ctx, &instance, history)
+		if err != nil {
+			return nil, err
+		}
+		coasties = append(coasties, coastie)
+	}
+	return coasties, nil
+}
+
+func (s *Server) coastie(ctx context.Context, instance *k8sv1alpha1.Coastie, history int) (coastie Coastie, err error) {
+	coastie = Coastie{
+		Namespace: instance.Namespace,
+		Name:      instance.Name,
+	}
+	if owner := metav1.GetControllerOf(instance); owner != nil && owner.Kind == "ClusterCoastie" {
+		coastie.ClusterCoastie = owner.Name
+	}
+
+	runs, err := s.runs(ctx, instance)
+	if err != nil {
+		return coastie, err
+	}
+	for _, testName := range instance.Spec.Tests {
+		result := instance.Status.TestResults[testName]
+		test := Test{
+			Name:         testName,
+			Status:       result.Status,
+			Availability: result.Availability,
+			Latency:      result.Latency,
+		}
+		for i, run := range runs[testName] {
+			if i == 0 {
+				latest := newRun(run, true)
+				test.Latest = &latest
+			}
+			if i >= history {
+				break
+			}
+			test.History = append(test.History, newRun(run, false))
+		}
+		coastie.Tests = append(coastie.Tests, test)
+	}
+	return coastie, nil
+}
+
+// runs returns the CoastieRuns of a Coastie by test, newest first
+func (s *Server) runs(ctx context.Context, instance *k8sv1alpha1.Coastie) (runs map[string][]k8sv1alpha1.CoastieRun, err error) {
+	opts := &client.ListOptions{}
+	opts.SetLabelSelector(fmt.Sprintf("%s=%s", k8sv1alpha1.CoastieRunCoastieLabel, instance.Name))
+	opts.InNamespace(instance.Namespace)
+
+	runList := &k8sv1alpha1.CoastieRunList{}
+	err = s.client.List(ctx, opts, runList)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(runList.Items, func(i, j int) bool {
+		return runList.Items[i].Status.StartTime.After(runList.Items[j].Status.StartTime.Time)
+	})
+	runs = make(map[string][]k8sv1alpha1.CoastieRun)
+	for _, run := range runList.Items {
+		runs[run.Spec.Test] = append(runs[run.Spec.Test], run)
+	}
+	return runs, nil
+}
+
+func newRun(run k8sv1alpha1.CoastieRun, withNodes bool) Run {
+	r := Run{
+		Name:      run.Name,
+		StartTime: run.Status.StartTime,
+		EndTime:   run.Status.EndTime,
+		Result:    run.Status.Result,
+		Message:   run.Status.Message,
+		Latency:   run.Status.Latency,
+	}
+	if withNodes {
+		r.Nodes = run.Status.Nodes
+	}
+	return r
+}
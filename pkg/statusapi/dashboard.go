@@ -0,0 +1,30 @@
+package statusapi
+
+import (
+	"embed"
+	"net/http"
+)
+
+// dashboardFiles is the web dashboard, a static page rendering the results of the status API
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardIndex serves the dashboard page on /
+func dashboardIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	if !allowRead(w, req) {
+		return
+	}
+	index, err := dashboardFiles.ReadFile("dashboard/index.html")
+	if err != nil {
+		log.Error(err, "Failed to read dashboard")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(index)
+}
@@ -0,0 +1,200 @@
+// Coastie dashboard, renders the results of the status API
+(function () {
+  "use strict";
+
+  var refreshSeconds = 30;
+  var historyLength = 50;
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (child) {
+      node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+    });
+    return node;
+  }
+
+  function svg(tag, attrs, children) {
+    var node = document.createElementNS("http://www.w3.org/2000/svg", tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (child) {
+      node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+    });
+    return node;
+  }
+
+  // Durations are serialized by Go, for example "1m2.5s" or "350ms"
+  function seconds(duration) {
+    if (!duration) {
+      return null;
+    }
+    var total = 0;
+    var re = /([\d.]+)(h|ms|us|µs|ns|m|s)/g;
+    var units = { h: 3600, m: 60, s: 1, ms: 1e-3, us: 1e-6, "µs": 1e-6, ns: 1e-9 };
+    var match;
+    while ((match = re.exec(duration)) !== null) {
+      total += parseFloat(match[1]) * units[match[2]];
+    }
+    return total;
+  }
+
+  function nodeNames(coastie) {
+    var names = {};
+    coastie.tests.forEach(function (test) {
+      ((test.latest && test.latest.nodes) || []).forEach(function (node) {
+        names[node.nodename] = true;
+      });
+    });
+    return Object.keys(names).sort();
+  }
+
+  // Red and green grid of tests by nodes, from the latest run of every test
+  function grid(coastie) {
+    var nodes = nodeNames(coastie);
+    if (nodes.length === 0) {
+      return el("p", { class: "empty" }, ["No per node results recorded yet"]);
+    }
+    var header = el("tr", {}, [el("th", {}, ["Node"])]);
+    coastie.tests.forEach(function (test) {
+      header.appendChild(el("th", {}, [test.name.toUpperCase()]));
+    });
+    var rows = [header];
+    nodes.forEach(function (nodeName) {
+      var row = el("tr", {}, [el("th", { class: "node" }, [nodeName])]);
+      coastie.tests.forEach(function (test) {
+        var result = ((test.latest && test.latest.nodes) || []).filter(function (node) {
+          return node.nodename === nodeName;
+        })[0];
+        var status = result ? result.status : "unknown";
+        var title = result ? result.message || result.status : "No result";
+        row.appendChild(el("td", { class: status, title: title }, [result ? result.status : "-"]));
+      });
+      rows.push(row);
+    });
+    return el("table", { class: "grid" }, rows);
+  }
+
+  // Bar chart of the pod startup latency per node, from the latest run of a test
+  function startupChart(test) {
+    var nodes = ((test.latest && test.latest.nodes) || []).filter(function (node) {
+      return node.startuplatency;
+    });
+    if (nodes.length === 0) {
+      return null;
+    }
+    var barHeight = 16;
+    var labelWidth = 160;
+    var width = 480;
+    var max = Math.max.apply(null, nodes.map(function (node) {
+      return seconds(node.startuplatency);
+    })) || 1;
+    var chart = svg("svg", { width: width, height: nodes.length * (barHeight + 4) + 4 });
+    nodes.forEach(function (node, i) {
+      var value = seconds(node.startuplatency);
+      var y = i * (barHeight + 4) + 2;
+      var barWidth = Math.max(1, (width - labelWidth - 60) * value / max);
+      chart.appendChild(svg("text", { x: 0, y: y + barHeight - 4 }, [node.nodename]));
+      chart.appendChild(svg("rect", { x: labelWidth, y: y, width: barWidth, height: barHeight }));
+      chart.appendChild(svg("text", { x: labelWidth + barWidth + 4, y: y + barHeight - 4 }, [value.toFixed(1) + "s"]));
+    });
+    return el("div", { class: "chart" }, [el("h3", {}, [test.name.toUpperCase() + " pod startup latency"]), chart]);
+  }
+
+  // Failed runs from the history, along with the failing nodes of the latest run
+  function failures(coastie) {
+    var items = [];
+    coastie.tests.forEach(function (test) {
+      (test.history || []).forEach(function (run) {
+        if (run.result !== "Failed") {
+          return;
+        }
+        var detail = [
+          el("strong", {}, [test.name.toUpperCase()]),
+          " ",
+          el("span", { class: "when" }, [new Date(run.endtime).toLocaleString()]),
+          el("br"),
+          run.message || "No diagnostics recorded",
+        ];
+        if (test.latest && test.latest.name === run.name) {
+          (test.latest.nodes || []).forEach(function (node) {
+            if (node.status === "Failed") {
+              detail.push(el("br"), node.nodename + ": " + (node.message || node.status));
+            }
+          });
+        }
+        items.push({ time: run.endtime, node: el("li", {}, detail) });
+      });
+    });
+    if (items.length === 0) {
+      return el("p", { class: "empty" }, ["No recent failures"]);
+    }
+    items.sort(function (a, b) {
+      return a.time < b.time ? 1 : -1;
+    });
+    return el("ul", { class: "failures" }, items.slice(0, 20).map(function (item) {
+      return item.node;
+    }));
+  }
+
+  function availability(coastie) {
+    var lines = [];
+    coastie.tests.forEach(function (test) {
+      (test.availability || []).forEach(function (window) {
+        if (window.availability) {
+          lines.push(test.name.toUpperCase() + " " + window.window + ": " + window.availability + "%");
+        }
+      });
+    });
+    return lines.length ? el("p", { class: "availability" }, [lines.join(" · ")]) : null;
+  }
+
+  function render(list) {
+    var main = document.getElementById("coasties");
+    main.innerHTML = "";
+    if (!list.items || list.items.length === 0) {
+      main.appendChild(el("p", { class: "empty" }, ["No Coasties found"]));
+    }
+    (list.items || []).forEach(function (coastie) {
+      var title = coastie.namespace + "/" + coastie.name;
+      if (coastie.clustercoastie) {
+        title += " (ClusterCoastie " + coastie.clustercoastie + ")";
+      }
+      var charts = el("div", { class: "charts" });
+      coastie.tests.forEach(function (test) {
+        var chart = startupChart(test);
+        if (chart) {
+          charts.appendChild(chart);
+        }
+      });
+      var children = [el("h2", {}, [title])];
+      var avail = availability(coastie);
+      if (avail) {
+        children.push(avail);
+      }
+      children.push(grid(coastie), charts, el("h3", {}, ["Recent failures"]), failures(coastie));
+      main.appendChild(el("section", { class: "coastie" }, children));
+    });
+    document.getElementById("updated").textContent = "Updated " + new Date(list.generatedat).toLocaleTimeString();
+  }
+
+  function refresh() {
+    fetch("api/v1/coasties?history=" + historyLength)
+      .then(function (resp) {
+        if (!resp.ok) {
+          throw new Error("status API returned " + resp.status);
+        }
+        return resp.json();
+      })
+      .then(render)
+      .catch(function (err) {
+        document.getElementById("updated").textContent = "Update failed: " + err.message;
+      });
+  }
+
+  refresh();
+  setInterval(refresh, refreshSeconds * 1000);
+})();
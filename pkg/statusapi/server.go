@@ -0,0 +1,137 @@
+// Package statusapi serves a read-only JSON API of the Coasties watched by the operator and their test results,
+// so dashboards and status pages can consume them without Kubernetes credentials, along with a built-in
+// web dashboard rendering them.
+package statusapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("statusapi")
+
+// Number of past runs returned per test when the request does not ask for a history length
+const defaultHistory = 10
+
+// Server serves the status API on addr
+type Server struct {
+	client client.Client
+	addr   string
+	mux    *http.ServeMux
+}
+
+// Add creates a new status API Server and adds it to the Manager, it is started along with the Manager
+func Add(mgr manager.Manager, addr string) error {
+	s := &Server{
+		client: mgr.GetClient(),
+		addr:   addr,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/api/v1/coasties", s.listCoasties)
+	s.mux.HandleFunc("/api/v1/coasties/", s.getCoastie)
+	s.mux.Handle("/dashboard/", http.FileServer(http.FS(dashboardFiles)))
+	s.mux.HandleFunc("/", dashboardIndex)
+	return mgr.Add(s)
+}
+
+// Start serves the status API until stop is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:    s.addr,
+		Handler: s.mux,
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		log.Info("Serving status API", "Address", s.addr)
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	case err := <-errChan:
+		return err
+	}
+}
+
+// listCoasties serves GET /api/v1/coasties
+func (s *Server) listCoasties(w http.ResponseWriter, req *http.Request) {
+	if !allowRead(w, req) {
+		return
+	}
+	coasties, err := s.coasties(context.TODO(), "", "", historyLength(req))
+	if err != nil {
+		log.Error(err, "Failed to list Coasties")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, CoastieList{
+		GeneratedAt: time.Now(),
+		Items:       coasties,
+	})
+}
+
+// getCoastie serves GET /api/v1/coasties/<namespace>/<name>
+func (s *Server) getCoastie(w http.ResponseWriter, req *http.Request) {
+	if !allowRead(w, req) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/v1/coasties/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		writeError(w, http.StatusNotFound, "expected /api/v1/coasties/<namespace>/<name>")
+		return
+	}
+	coasties, err := s.coasties(context.TODO(), parts[0], parts[1], historyLength(req))
+	if err != nil {
+		log.Error(err, "Failed to get Coastie", "Namespace", parts[0], "Name", parts[1])
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if len(coasties) == 0 {
+		writeError(w, http.StatusNotFound, "coastie not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, coasties[0])
+}
+
+func allowRead(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the status API is read-only")
+		return false
+	}
+	return true
+}
+
+// historyLength returns the number of past runs per test requested with ?history=N
+func historyLength(req *http.Request) int {
+	history, err := strconv.Atoi(req.URL.Query().Get("history"))
+	if err != nil || history < 0 {
+		return defaultHistory
+	}
+	return history
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Error(err, "Failed to write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
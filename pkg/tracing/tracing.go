@@ -0,0 +1,72 @@
+// Package tracing sets up OpenTelemetry tracing of the operator, exported over OTLP.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/jmainguy/coastie-operator/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("tracing")
+
+// Name of the instrumentation library and the default service name of the exported spans
+const instrumentationName = "github.com/jmainguy/coastie-operator"
+
+// Attribute keys set on the spans of the operator
+const (
+	CoastieNameKey      = attribute.Key("coastie.name")
+	CoastieNamespaceKey = attribute.Key("coastie.namespace")
+	TestKey             = attribute.Key("coastie.test")
+	NodeKey             = attribute.Key("k8s.node.name")
+	AttemptKey          = attribute.Key("coastie.attempt")
+	TargetKey           = attribute.Key("coastie.target")
+)
+
+// Setup installs a tracer provider exporting spans over OTLP/gRPC when an OTLP endpoint is configured
+// through the standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables.
+// The returned function flushes and stops the exporter, tracing stays a no-op when no endpoint is configured
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	shutdown = func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		log.Info("No OTLP endpoint configured, tracing is disabled")
+		return shutdown, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return shutdown, err
+	}
+	// Service name and attributes can be overridden with OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "coastie-operator"),
+			attribute.String("service.version", version.Version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return shutdown, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Info("Exporting traces over OTLP")
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the operator
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}